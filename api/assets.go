@@ -0,0 +1,253 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/protobuf/proto"
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+const maxPageRetries = 3
+
+func (s *Session) getPlatform() protos.Platform {
+	if s.deviceInfo.DeviceBrand == "Apple" {
+		return protos.Platform_IOS
+	}
+	return protos.Platform_ANDROID
+}
+
+// DownloadRemoteConfigVersion returns the timestamps of the latest item templates and asset digest
+func (s *Session) DownloadRemoteConfigVersion(ctx context.Context) (*protos.DownloadRemoteConfigVersionResponse, error) {
+	message := &protos.DownloadRemoteConfigVersionMessage{
+		Platform:           s.getPlatform(),
+		DeviceManufacturer: s.deviceInfo.HardwareManufacturer,
+		DeviceModel:        s.deviceInfo.DeviceModelBoot,
+		Locale:             locale,
+		AppVersion:         appVersion,
+	}
+	response, err := s.callRequest(ctx, protos.RequestType_DOWNLOAD_REMOTE_CONFIG_VERSION, message)
+	if err != nil {
+		return nil, err
+	}
+
+	version := &protos.DownloadRemoteConfigVersionResponse{}
+	err = s.decodeReturn(response, 0, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return version, GetErrorFromStatus(response.StatusCode)
+}
+
+// GetAssetDigest returns the metadata of all assets, following the pages of the response
+func (s *Session) GetAssetDigest(ctx context.Context) (*protos.GetAssetDigestResponse, error) {
+	message := &protos.GetAssetDigestMessage{
+		Platform:           s.getPlatform(),
+		DeviceManufacturer: s.deviceInfo.HardwareManufacturer,
+		DeviceModel:        s.deviceInfo.DeviceModelBoot,
+		Locale:             locale,
+		AppVersion:         appVersion,
+		Paginate:           true,
+	}
+
+	digest := &protos.GetAssetDigestResponse{}
+	retries := 0
+	for {
+		response, err := s.callRequest(ctx, protos.RequestType_GET_ASSET_DIGEST, message)
+		if err != nil {
+			return nil, err
+		}
+
+		page := &protos.GetAssetDigestResponse{}
+		err = s.decodeReturn(response, 0, page)
+		if err != nil {
+			return nil, err
+		}
+		err = GetErrorFromStatus(response.StatusCode)
+		if isFailure(err) {
+			return nil, err
+		}
+
+		// The remote service asks for the same page to be requested again
+		if page.Result == protos.GetAssetDigestResponse_RETRY {
+			retries++
+			if retries > maxPageRetries {
+				return nil, ErrRequest
+			}
+			continue
+		}
+		retries = 0
+
+		digest.Digest = append(digest.Digest, page.Digest...)
+		digest.TimestampMs = page.TimestampMs
+		digest.Result = page.Result
+
+		if page.Result != protos.GetAssetDigestResponse_PAGE || page.PageOffset == 0 {
+			return digest, err
+		}
+		message.PageOffset = page.PageOffset
+		message.PageTimestamp = page.TimestampMs
+	}
+}
+
+// DownloadItemTemplates returns the game master item templates, following the pages of the response
+func (s *Session) DownloadItemTemplates(ctx context.Context) (*protos.DownloadItemTemplatesResponse, error) {
+	message := &protos.DownloadItemTemplatesMessage{
+		Paginate: true,
+	}
+
+	templates := &protos.DownloadItemTemplatesResponse{}
+	retries := 0
+	for {
+		response, err := s.callRequest(ctx, protos.RequestType_DOWNLOAD_ITEM_TEMPLATES, message)
+		if err != nil {
+			return nil, err
+		}
+
+		page := &protos.DownloadItemTemplatesResponse{}
+		err = s.decodeReturn(response, 0, page)
+		if err != nil {
+			return nil, err
+		}
+		err = GetErrorFromStatus(response.StatusCode)
+		if isFailure(err) {
+			return nil, err
+		}
+
+		// The remote service asks for the same page to be requested again
+		if page.Result == protos.DownloadItemTemplatesResponse_RETRY {
+			retries++
+			if retries > maxPageRetries {
+				return nil, ErrRequest
+			}
+			continue
+		}
+		retries = 0
+
+		templates.ItemTemplates = append(templates.ItemTemplates, page.ItemTemplates...)
+		templates.TimestampMs = page.TimestampMs
+		templates.Result = page.Result
+		templates.Success = page.Success
+
+		if page.Result != protos.DownloadItemTemplatesResponse_PAGE || page.PageOffset == 0 {
+			return templates, err
+		}
+		message.PageOffset = page.PageOffset
+		message.PageTimestamp = page.TimestampMs
+	}
+}
+
+// GetDownloadURLs returns the URLs from where the assets can be downloaded
+func (s *Session) GetDownloadURLs(ctx context.Context, assetIDs []string) (*protos.GetDownloadUrlsResponse, error) {
+	message := &protos.GetDownloadUrlsMessage{
+		AssetId: assetIDs,
+	}
+	response, err := s.callRequest(ctx, protos.RequestType_GET_DOWNLOAD_URLS, message)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := &protos.GetDownloadUrlsResponse{}
+	err = s.decodeReturn(response, 0, urls)
+	if err != nil {
+		return nil, err
+	}
+
+	return urls, GetErrorFromStatus(response.StatusCode)
+}
+
+// AssetCache keeps item templates and asset digests in a local directory so they are only downloaded when they change
+type AssetCache struct {
+	dir string
+}
+
+// NewAssetCache constructs an asset cache storing its files in the directory
+func NewAssetCache(dir string) *AssetCache {
+	return &AssetCache{
+		dir: dir,
+	}
+}
+
+func (c *AssetCache) path(name string, timestamp uint64) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%s_%d.pb", name, timestamp))
+}
+
+func (c *AssetCache) load(name string, timestamp uint64, message proto.Message) bool {
+	b, err := ioutil.ReadFile(c.path(name, timestamp))
+	if err != nil {
+		return false
+	}
+	return proto.Unmarshal(b, message) == nil
+}
+
+func (c *AssetCache) store(name string, timestamp uint64, message proto.Message) error {
+	b, err := proto.Marshal(message)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(c.dir, 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path(name, timestamp), b, 0644)
+}
+
+// ItemTemplates returns the item templates from the cache, downloading them if the remote config has a newer version
+func (c *AssetCache) ItemTemplates(ctx context.Context, s *Session) (*protos.DownloadItemTemplatesResponse, error) {
+	version, err := s.DownloadRemoteConfigVersion(ctx)
+	if isFailure(err) {
+		return nil, err
+	}
+
+	templates := &protos.DownloadItemTemplatesResponse{}
+	if c.load("item_templates", version.ItemTemplatesTimestampMs, templates) {
+		return templates, nil
+	}
+
+	templates, err = s.DownloadItemTemplates(ctx)
+	if isFailure(err) {
+		return nil, err
+	}
+	// Only complete templates are kept for the version
+	if templates.Result != protos.DownloadItemTemplatesResponse_SUCCESS {
+		return templates, nil
+	}
+	err = c.store("item_templates", version.ItemTemplatesTimestampMs, templates)
+	if err != nil {
+		return templates, err
+	}
+
+	return templates, nil
+}
+
+// AssetDigest returns the asset digest from the cache, downloading it if the remote config has a newer version
+func (c *AssetCache) AssetDigest(ctx context.Context, s *Session) (*protos.GetAssetDigestResponse, error) {
+	version, err := s.DownloadRemoteConfigVersion(ctx)
+	if isFailure(err) {
+		return nil, err
+	}
+
+	digest := &protos.GetAssetDigestResponse{}
+	if c.load("asset_digest", version.AssetDigestTimestampMs, digest) {
+		return digest, nil
+	}
+
+	digest, err = s.GetAssetDigest(ctx)
+	if isFailure(err) {
+		return nil, err
+	}
+	// Only a complete digest is kept for the version
+	if digest.Result != protos.GetAssetDigestResponse_SUCCESS {
+		return digest, nil
+	}
+	err = c.store("asset_digest", version.AssetDigestTimestampMs, digest)
+	if err != nil {
+		return digest, err
+	}
+
+	return digest, nil
+}
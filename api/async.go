@@ -0,0 +1,160 @@
+package api
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncStats are the counts of an async feed
+type AsyncStats struct {
+	// Queued is the number of entries waiting in the buffer
+	Queued int
+	// Processed is the number of entries pushed on to the feed
+	Processed uint64
+	// Dropped is the number of entries discarded because the buffer was full or the feed was closed
+	Dropped uint64
+}
+
+// AsyncFeed is a feed decorator that buffers entries and pushes them on to the feed from worker goroutines,
+// so a slow feed does not hold up the session. Entries may be pushed out of order with more than one worker.
+type AsyncFeed struct {
+	feed   EntryFeed
+	policy OverflowPolicy
+	queue  chan *FeedEntry
+
+	mutex  sync.RWMutex
+	closed bool
+
+	// overflow serializes making room in the buffer when dropping the oldest entries
+	overflow sync.Mutex
+
+	pending     int
+	pendingCond *sync.Cond
+	workers     sync.WaitGroup
+
+	processed uint64
+	dropped   uint64
+}
+
+// NewAsyncFeed constructs an async feed buffering up to size entries for the number of workers, the policy
+// decides what happens to entries pushed when the buffer is full. The buffer holds at least one entry.
+func NewAsyncFeed(feed Feed, workers, size int, policy OverflowPolicy) *AsyncFeed {
+	if workers < 1 {
+		workers = 1
+	}
+	if size < 1 {
+		size = 1
+	}
+	f := &AsyncFeed{
+		feed:        toEntryFeed(feed),
+		policy:      policy,
+		queue:       make(chan *FeedEntry, size),
+		pendingCond: sync.NewCond(&sync.Mutex{}),
+	}
+	f.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go f.work()
+	}
+	return f
+}
+
+func (f *AsyncFeed) work() {
+	defer f.workers.Done()
+	for entry := range f.queue {
+		f.feed.PushEntry(entry)
+		atomic.AddUint64(&f.processed, 1)
+		f.done()
+	}
+}
+
+func (f *AsyncFeed) add() {
+	f.pendingCond.L.Lock()
+	f.pending++
+	f.pendingCond.L.Unlock()
+}
+
+func (f *AsyncFeed) done() {
+	f.pendingCond.L.Lock()
+	f.pending--
+	if f.pending == 0 {
+		f.pendingCond.Broadcast()
+	}
+	f.pendingCond.L.Unlock()
+}
+
+func (f *AsyncFeed) drop() {
+	atomic.AddUint64(&f.dropped, 1)
+}
+
+// PushEntry queues the entry, following the overflow policy when the buffer is full
+func (f *AsyncFeed) PushEntry(entry *FeedEntry) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	if f.closed {
+		f.drop()
+		return
+	}
+
+	f.add()
+	switch f.policy {
+	case OverflowBlock:
+		f.queue <- entry
+	case OverflowDropOldest:
+		f.overflow.Lock()
+		defer f.overflow.Unlock()
+		for {
+			select {
+			case f.queue <- entry:
+				return
+			default:
+			}
+			select {
+			case <-f.queue:
+				f.drop()
+				f.done()
+			default:
+			}
+		}
+	default:
+		select {
+		case f.queue <- entry:
+		default:
+			f.drop()
+			f.done()
+		}
+	}
+}
+
+// Push queues the entry, following the overflow policy when the buffer is full
+func (f *AsyncFeed) Push(entry interface{}) {
+	f.PushEntry(&FeedEntry{Message: entry})
+}
+
+// Flush waits until all queued entries have been pushed on to the feed
+func (f *AsyncFeed) Flush() {
+	f.pendingCond.L.Lock()
+	defer f.pendingCond.L.Unlock()
+	for f.pending > 0 {
+		f.pendingCond.Wait()
+	}
+}
+
+// Close stops accepting entries and waits until the queued entries have been pushed on to the feed
+func (f *AsyncFeed) Close() {
+	f.mutex.Lock()
+	if !f.closed {
+		f.closed = true
+		close(f.queue)
+	}
+	f.mutex.Unlock()
+	f.workers.Wait()
+}
+
+// Stats returns the counts of queued, processed and dropped entries
+func (f *AsyncFeed) Stats() AsyncStats {
+	return AsyncStats{
+		Queued:    len(f.queue),
+		Processed: atomic.LoadUint64(&f.processed),
+		Dropped:   atomic.LoadUint64(&f.dropped),
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// gateFeed records entries once it is opened
+type gateFeed struct {
+	gate    chan struct{}
+	mutex   sync.Mutex
+	entries []interface{}
+}
+
+func (f *gateFeed) Push(entry interface{}) {
+	<-f.gate
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.entries = append(f.entries, entry)
+}
+
+func TestAsyncFeedDropNewest(t *testing.T) {
+	slow := &gateFeed{gate: make(chan struct{})}
+	feed := NewAsyncFeed(slow, 1, 2, OverflowDropNewest)
+
+	// The first entry is held by the worker, the next two are buffered
+	feed.Push(0)
+	for feed.Stats().Queued != 0 {
+		runtime.Gosched()
+	}
+	for i := 1; i <= 4; i++ {
+		feed.Push(i)
+	}
+	if stats := feed.Stats(); stats.Queued != 2 || stats.Dropped != 2 {
+		t.Errorf("Expected 2 queued and 2 dropped entries, got %+v", stats)
+	}
+
+	close(slow.gate)
+	feed.Flush()
+	if stats := feed.Stats(); stats.Processed != 3 || stats.Queued != 0 {
+		t.Errorf("Expected the queued entries to be flushed, got %+v", stats)
+	}
+	if slow.entries[1] != 1 || slow.entries[2] != 2 {
+		t.Errorf("Expected the newest entries to be dropped, got %v", slow.entries)
+	}
+
+	feed.Close()
+	feed.Push(5)
+	if stats := feed.Stats(); stats.Dropped != 3 {
+		t.Errorf("Expected entries pushed after closing to be dropped, got %+v", stats)
+	}
+}
+
+func TestAsyncFeedDropOldest(t *testing.T) {
+	slow := &gateFeed{gate: make(chan struct{})}
+	feed := NewAsyncFeed(slow, 1, 2, OverflowDropOldest)
+
+	feed.Push(0)
+	for feed.Stats().Queued != 0 {
+		runtime.Gosched()
+	}
+	for i := 1; i <= 4; i++ {
+		feed.Push(i)
+	}
+
+	close(slow.gate)
+	feed.Close()
+	if len(slow.entries) != 3 || slow.entries[1] != 3 || slow.entries[2] != 4 {
+		t.Errorf("Expected the oldest entries to be dropped and the rest drained, got %v", slow.entries)
+	}
+}
+
+func TestAsyncFeedSmallBuffer(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		open := &gateFeed{gate: make(chan struct{})}
+		close(open.gate)
+		feed := NewAsyncFeed(open, 1, size, OverflowDropOldest)
+		for i := 0; i < 10; i++ {
+			feed.Push(i)
+		}
+		feed.Close()
+		if stats := feed.Stats(); stats.Processed+stats.Dropped != 10 || stats.Processed == 0 {
+			t.Errorf("Expected a buffer of size %d to hold one entry, got %+v", size, stats)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy decides what happens to entries pushed to a consumer that is not keeping up
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the push until there is room for the entry
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest buffered entry to make room for the new entry
+	OverflowDropOldest
+	// OverflowDropNewest discards the new entry
+	OverflowDropNewest
+)
+
+// Subscription is a registration on a broker delivering entries of the subscribed types on a channel
+type Subscription struct {
+	// C receives the entries, or *FeedEntry values for subscriptions made with SubscribeEntries. It is closed
+	// when the subscription is cancelled.
+	C <-chan interface{}
+
+	broker    *Broker
+	entries   chan interface{}
+	done      chan struct{}
+	once      sync.Once
+	types     map[reflect.Type]bool
+	withEntry bool
+	policy    OverflowPolicy
+	mutex     sync.Mutex
+	dropped   uint64
+}
+
+// Dropped returns the number of entries discarded because the subscriber was not keeping up
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Unsubscribe cancels the subscription and closes its channel
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		// Releases pushes blocked on the subscription before waiting for them to finish
+		close(s.done)
+		s.broker.mutex.Lock()
+		delete(s.broker.subscriptions, s)
+		s.broker.mutex.Unlock()
+		close(s.entries)
+	})
+}
+
+func (s *Subscription) accepts(entry interface{}) bool {
+	return len(s.types) == 0 || s.types[reflect.TypeOf(entry)]
+}
+
+func (s *Subscription) deliver(entry interface{}) {
+	switch s.policy {
+	case OverflowBlock:
+		select {
+		case s.entries <- entry:
+		case <-s.done:
+		}
+	case OverflowDropOldest:
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		for {
+			select {
+			case s.entries <- entry:
+				return
+			default:
+			}
+			select {
+			case <-s.entries:
+				atomic.AddUint64(&s.dropped, 1)
+			default:
+				// Nothing is buffered to make room with
+				atomic.AddUint64(&s.dropped, 1)
+				return
+			}
+		}
+	default:
+		select {
+		case s.entries <- entry:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// Broker is a feed fanning out entries to the subscriptions registered for their types
+type Broker struct {
+	mutex         sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+}
+
+// NewBroker constructs a broker without any subscriptions
+func NewBroker() *Broker {
+	return &Broker{
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe registers a subscription buffering up to size entries of the same types as the given
+// values, e.g. (*protos.GetMapObjectsResponse)(nil). All entries are delivered when no types are given.
+func (b *Broker) Subscribe(size int, policy OverflowPolicy, types ...interface{}) *Subscription {
+	return b.subscribe(size, policy, false, types)
+}
+
+// SubscribeEntries registers a subscription like Subscribe, delivering each message together with its context as a
+// *FeedEntry
+func (b *Broker) SubscribeEntries(size int, policy OverflowPolicy, types ...interface{}) *Subscription {
+	return b.subscribe(size, policy, true, types)
+}
+
+func (b *Broker) subscribe(size int, policy OverflowPolicy, withEntry bool, types []interface{}) *Subscription {
+	entries := make(chan interface{}, size)
+	s := &Subscription{
+		C:         entries,
+		broker:    b,
+		entries:   entries,
+		done:      make(chan struct{}),
+		types:     make(map[reflect.Type]bool),
+		withEntry: withEntry,
+		policy:    policy,
+	}
+	for _, t := range types {
+		s.types[reflect.TypeOf(t)] = true
+	}
+
+	b.mutex.Lock()
+	b.subscriptions[s] = struct{}{}
+	b.mutex.Unlock()
+	return s
+}
+
+// Push delivers the entry to all subscriptions registered for its type
+func (b *Broker) Push(entry interface{}) {
+	b.PushEntry(&FeedEntry{Message: entry})
+}
+
+// PushEntry delivers the message of the entry to all subscriptions registered for its type
+func (b *Broker) PushEntry(entry *FeedEntry) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for s := range b.subscriptions {
+		if !s.accepts(entry.Message) {
+			continue
+		}
+		if s.withEntry {
+			s.deliver(entry)
+		} else {
+			s.deliver(entry.Message)
+		}
+	}
+}
+
+// Close cancels all subscriptions
+func (b *Broker) Close() {
+	b.mutex.RLock()
+	subscriptions := make([]*Subscription, 0, len(b.subscriptions))
+	for s := range b.subscriptions {
+		subscriptions = append(subscriptions, s)
+	}
+	b.mutex.RUnlock()
+
+	for _, s := range subscriptions {
+		s.Unsubscribe()
+	}
+}
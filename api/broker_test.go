@@ -0,0 +1,80 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker()
+	maps := broker.Subscribe(2, OverflowDropOldest, (*protos.GetMapObjectsResponse)(nil))
+	inventories := broker.Subscribe(2, OverflowDropNewest, (*protos.GetInventoryResponse)(nil))
+	all := broker.Subscribe(0, OverflowBlock)
+
+	received := make(chan int)
+	go func() {
+		count := 0
+		for range all.C {
+			count++
+		}
+		received <- count
+	}()
+
+	for i := 1; i <= 3; i++ {
+		broker.Push(&protos.GetMapObjectsResponse{MapCells: make([]*protos.MapCell, i)})
+		broker.Push(&protos.GetInventoryResponse{})
+	}
+
+	if maps.Dropped() != 1 || inventories.Dropped() != 1 {
+		t.Errorf("Expected one dropped entry per subscription, got %d and %d", maps.Dropped(), inventories.Dropped())
+	}
+	if first := (<-maps.C).(*protos.GetMapObjectsResponse); len(first.MapCells) != 2 {
+		t.Errorf("Expected the oldest map objects to be dropped, got %d cells", len(first.MapCells))
+	}
+
+	broker.Close()
+	if count := <-received; count != 6 {
+		t.Errorf("Expected the blocking subscription to receive all 6 entries, got %d", count)
+	}
+	if _, ok := <-inventories.C; !ok {
+		t.Errorf("Expected buffered entries to remain readable after unsubscribing")
+	}
+}
+
+func TestBrokerUnsubscribeBlocked(t *testing.T) {
+	broker := NewBroker()
+	s := broker.Subscribe(0, OverflowBlock)
+
+	pushed := make(chan struct{})
+	go func() {
+		broker.Push(&protos.GetPlayerResponse{})
+		close(pushed)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	s.Unsubscribe()
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected unsubscribing to release the blocked push")
+	}
+}
+
+func TestBrokerEntries(t *testing.T) {
+	broker := NewBroker()
+	entries := broker.SubscribeEntries(1, OverflowDropNewest, (*protos.GetMapObjectsResponse)(nil))
+	messages := broker.Subscribe(1, OverflowDropNewest, (*protos.GetMapObjectsResponse)(nil))
+	defer broker.Close()
+
+	response := &protos.GetMapObjectsResponse{}
+	broker.PushEntry(&FeedEntry{Message: response, Username: "ash"})
+
+	if entry := (<-entries.C).(*FeedEntry); entry.Message != response || entry.Username != "ash" {
+		t.Errorf("Expected the entry with its context, got %#v", entry)
+	}
+	if message := <-messages.C; message != response {
+		t.Errorf("Expected only the message, got %#v", message)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// ChallengeEvent is pushed to the feed when the remote service requires a captcha challenge to be solved
+type ChallengeEvent struct {
+	URL       string
+	Location  Location
+	Timestamp time.Time
+}
+
+func (s *Session) checkChallenge(response *envelope, index int) (*protos.CheckChallengeResponse, error) {
+	challenge := &protos.CheckChallengeResponse{}
+	err := s.decodeReturn(response, index, challenge)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.ShowChallenge {
+		return challenge, nil
+	}
+
+	s.push(response, index, &ChallengeEvent{
+		URL:       challenge.ChallengeUrl,
+		Location:  response.location,
+		Timestamp: response.received,
+	})
+
+	return challenge, &ErrChallengeRequired{URL: challenge.ChallengeUrl}
+}
+
+// CheckChallenge tells if the remote service requires a captcha challenge to be solved, the error is an ErrChallengeRequired if it does
+func (s *Session) CheckChallenge(ctx context.Context) (*protos.CheckChallengeResponse, error) {
+	response, err := s.callRequest(ctx, protos.RequestType_CHECK_CHALLENGE, &protos.CheckChallengeMessage{})
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.checkChallenge(response, 0)
+	if err != nil {
+		return challenge, err
+	}
+
+	return challenge, GetErrorFromStatus(response.StatusCode)
+}
+
+// VerifyChallenge clears a captcha challenge with the token retrieved by solving it
+func (s *Session) VerifyChallenge(ctx context.Context, token string) (*protos.VerifyChallengeResponse, error) {
+	message := &protos.VerifyChallengeMessage{
+		Token: token,
+	}
+	response, err := s.callRequest(ctx, protos.RequestType_VERIFY_CHALLENGE, message)
+	if err != nil {
+		return nil, err
+	}
+
+	verified := &protos.VerifyChallengeResponse{}
+	err = s.decodeReturn(response, 0, verified)
+	if err != nil {
+		return nil, err
+	}
+
+	return verified, GetErrorFromStatus(response.StatusCode)
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// Entries without a known expiry are remembered for this long
+const defaultDedupeTTL = 30 * time.Minute
+
+type dedupeEntry struct {
+	key     string
+	expires time.Time
+	index   int
+}
+
+// dedupeQueue is a heap of entries ordered by expiry
+type dedupeQueue []*dedupeEntry
+
+func (q dedupeQueue) Len() int           { return len(q) }
+func (q dedupeQueue) Less(i, j int) bool { return q[i].expires.Before(q[j].expires) }
+func (q dedupeQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *dedupeQueue) Push(x interface{}) {
+	entry := x.(*dedupeEntry)
+	entry.index = len(*q)
+	*q = append(*q, entry)
+}
+
+func (q *dedupeQueue) Pop() interface{} {
+	old := *q
+	entry := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return entry
+}
+
+// DedupeFeed is a feed decorator that forwards each wild Pokémon and lure only once until it expires.
+// Map objects responses are forwarded as a copy without the Pokémon and lures seen before, all other entries are passed
+// on as is. A DiffFeed after it would take the removed Pokémon and lures as gone, so diff before deduping.
+type DedupeFeed struct {
+	feed       Feed
+	entryFeed  EntryFeed
+	maxEntries int
+	ttl        time.Duration
+	now        func() time.Time
+
+	mutex sync.Mutex
+	seen  map[string]*dedupeEntry
+	queue dedupeQueue
+}
+
+// NewDedupeFeed constructs a dedupe feed remembering at most the number of Pokémon and lures together, the entries
+// closest to expiring are forgotten first when it is full
+func NewDedupeFeed(feed Feed, maxEntries int) *DedupeFeed {
+	return &DedupeFeed{
+		feed:       feed,
+		entryFeed:  toEntryFeed(feed),
+		maxEntries: maxEntries,
+		ttl:        defaultDedupeTTL,
+		now:        time.Now,
+		seen:       make(map[string]*dedupeEntry),
+		queue:      make(dedupeQueue, 0),
+	}
+}
+
+// SetClock replaces the clock used to expire entries
+func (f *DedupeFeed) SetClock(now func() time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = now
+}
+
+// SetTTL sets how long entries without a known expiry are remembered
+func (f *DedupeFeed) SetTTL(ttl time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.ttl = ttl
+}
+
+// Len returns the number of remembered entries
+func (f *DedupeFeed) Len() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.seen)
+}
+
+// remember returns true if the key was not seen before and remembers it until it expires
+func (f *DedupeFeed) remember(key string, expires time.Time, now time.Time) bool {
+	if _, ok := f.seen[key]; ok {
+		return false
+	}
+	if expires.IsZero() {
+		expires = now.Add(f.ttl)
+	}
+	if !expires.After(now) {
+		return false
+	}
+
+	for f.maxEntries > 0 && len(f.queue) >= f.maxEntries {
+		f.forget()
+	}
+	entry := &dedupeEntry{key: key, expires: expires}
+	heap.Push(&f.queue, entry)
+	f.seen[key] = entry
+	return true
+}
+
+func (f *DedupeFeed) forget() {
+	entry := heap.Pop(&f.queue).(*dedupeEntry)
+	delete(f.seen, entry.key)
+}
+
+func (f *DedupeFeed) expire(now time.Time) {
+	for len(f.queue) > 0 && !f.queue[0].expires.After(now) {
+		f.forget()
+	}
+}
+
+func pokemonKey(encounterID uint64) string {
+	return fmt.Sprintf("pokemon:%d", encounterID)
+}
+
+func lureKey(fortID string, lure *protos.FortLureInfo) string {
+	return fmt.Sprintf("lure:%s:%d", fortID, lure.LureExpiresTimestampMs)
+}
+
+// filterMapObjects returns a copy of the map objects response without the wild and catchable Pokémon and the lures
+// that have been forwarded before. Forts are kept without the lure info of a lure forwarded before.
+func (f *DedupeFeed) filterMapObjects(mapObjects *protos.GetMapObjectsResponse, now time.Time) *protos.GetMapObjectsResponse {
+	filtered := *mapObjects
+	filtered.MapCells = make([]*protos.MapCell, 0, len(mapObjects.MapCells))
+
+	// A Pokémon seen for the first time is kept in every cell of the response it is in
+	forwarded := make(map[uint64]bool)
+	for _, mapCell := range mapObjects.MapCells {
+		cell := *mapCell
+		cell.WildPokemons = make([]*protos.WildPokemon, 0, len(mapCell.WildPokemons))
+		for _, pokemon := range mapCell.WildPokemons {
+			if forwarded[pokemon.EncounterId] || f.remember(pokemonKey(pokemon.EncounterId), getDespawnTime(pokemon, now), now) {
+				forwarded[pokemon.EncounterId] = true
+				cell.WildPokemons = append(cell.WildPokemons, pokemon)
+			}
+		}
+		cell.CatchablePokemons = make([]*protos.MapPokemon, 0, len(mapCell.CatchablePokemons))
+		for _, pokemon := range mapCell.CatchablePokemons {
+			var expires time.Time
+			if pokemon.ExpirationTimestampMs > 0 {
+				expires = fromTimestampMs(pokemon.ExpirationTimestampMs)
+			}
+			if forwarded[pokemon.EncounterId] || f.remember(pokemonKey(pokemon.EncounterId), expires, now) {
+				forwarded[pokemon.EncounterId] = true
+				cell.CatchablePokemons = append(cell.CatchablePokemons, pokemon)
+			}
+		}
+		cell.Forts = make([]*protos.FortData, 0, len(mapCell.Forts))
+		for _, fort := range mapCell.Forts {
+			if lure := fort.LureInfo; lure != nil && !f.remember(lureKey(fort.Id, lure), fromTimestampMs(lure.LureExpiresTimestampMs), now) {
+				unlured := *fort
+				unlured.LureInfo = nil
+				fort = &unlured
+			}
+			cell.Forts = append(cell.Forts, fort)
+		}
+		filtered.MapCells = append(filtered.MapCells, &cell)
+	}
+	return &filtered
+}
+
+// filter returns the entry to forward, or nil if it has been forwarded before
+func (f *DedupeFeed) filter(entry interface{}) interface{} {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := f.now()
+	f.expire(now)
+
+	switch e := entry.(type) {
+	case *protos.GetMapObjectsResponse:
+		return f.filterMapObjects(e, now)
+	case *protos.WildPokemon:
+		if f.remember(pokemonKey(e.EncounterId), getDespawnTime(e, now), now) {
+			return e
+		}
+	default:
+		return entry
+	}
+	return nil
+}
+
+// Push forwards the entry if it has not been seen before, map objects responses are forwarded without the
+// Pokémon and lures that have been seen before
+func (f *DedupeFeed) Push(entry interface{}) {
+	if forward := f.filter(entry); forward != nil {
+		f.feed.Push(forward)
+	}
+}
+
+// PushEntry forwards the message of the entry like Push, with the context of the entry
+func (f *DedupeFeed) PushEntry(entry *FeedEntry) {
+	if message := f.filter(entry.Message); message != nil {
+		forward := *entry
+		forward.Message = message
+		f.entryFeed.PushEntry(&forward)
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+type recordFeed struct {
+	entries []interface{}
+}
+
+func (f *recordFeed) Push(entry interface{}) {
+	f.entries = append(f.entries, entry)
+}
+
+func TestDedupeFeed(t *testing.T) {
+	now := time.Unix(1000, 0)
+	record := &recordFeed{}
+	feed := NewDedupeFeed(record, 2)
+	feed.SetClock(func() time.Time { return now })
+
+	pidgey := &protos.WildPokemon{EncounterId: 1, LastModifiedTimestampMs: 1000000, TimeTillHiddenMs: 600000}
+	lured := &protos.FortData{Id: "pokestop", LureInfo: &protos.FortLureInfo{FortId: "pokestop", LureExpiresTimestampMs: 1300000}}
+	mapObjects := &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{
+		{S2CellId: 1, WildPokemons: []*protos.WildPokemon{pidgey}, Forts: []*protos.FortData{lured}},
+		{S2CellId: 2, WildPokemons: []*protos.WildPokemon{pidgey}},
+	}}
+
+	feed.Push(mapObjects)
+	feed.Push(mapObjects)
+	feed.Push(pidgey)
+	if len(record.entries) != 2 {
+		t.Fatalf("Expected both map objects responses to be forwarded, got %d entries", len(record.entries))
+	}
+	first := record.entries[0].(*protos.GetMapObjectsResponse)
+	if len(first.MapCells) != 2 || len(first.MapCells[0].WildPokemons) != 1 || len(first.MapCells[1].WildPokemons) != 1 {
+		t.Errorf("Expected the first response to keep the Pokémon in both cells, got %#v", first.MapCells)
+	}
+	second := record.entries[1].(*protos.GetMapObjectsResponse)
+	if len(second.MapCells) != 2 || len(second.MapCells[0].WildPokemons) != 0 || len(second.MapCells[0].Forts) != 1 {
+		t.Errorf("Expected the second response to keep only the fort, got %#v", second.MapCells)
+	}
+	if len(mapObjects.MapCells[0].WildPokemons) != 1 {
+		t.Error("Expected the response itself not to be changed")
+	}
+
+	feed.Push(&protos.GetPlayerResponse{})
+	if len(record.entries) != 3 {
+		t.Errorf("Expected other entries to be passed on, got %d entries", len(record.entries))
+	}
+
+	// The lure expires first and is forgotten
+	now = time.Unix(1400, 0)
+	feed.Push(&protos.WildPokemon{EncounterId: 2})
+	if feed.Len() != 2 {
+		t.Errorf("Expected 2 remembered entries, got %d", feed.Len())
+	}
+
+	// The despawned Pokémon is forgotten, the bound evicts the soonest expiring entry
+	now = time.Unix(1700, 0)
+	feed.Push(pidgey)
+	feed.Push(&protos.WildPokemon{EncounterId: 3})
+	feed.Push(&protos.WildPokemon{EncounterId: 4})
+	if feed.Len() != 2 {
+		t.Errorf("Expected the feed to be bounded to 2 entries, got %d", feed.Len())
+	}
+	if len(record.entries) != 6 {
+		t.Errorf("Expected 6 forwarded entries, got %d", len(record.entries))
+	}
+}
+
+func TestDedupeFeedLure(t *testing.T) {
+	now := time.Unix(1000, 0)
+	record := &recordFeed{}
+	feed := NewDedupeFeed(record, 10)
+	feed.SetClock(func() time.Time { return now })
+
+	lured := &protos.FortData{Id: "pokestop", LureInfo: &protos.FortLureInfo{FortId: "pokestop", LureExpiresTimestampMs: 1300000}}
+	mapObjects := &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{S2CellId: 1, Forts: []*protos.FortData{lured}}}}
+	feed.Push(mapObjects)
+	feed.Push(mapObjects)
+
+	lures := 0
+	for _, entry := range record.entries {
+		for _, cell := range entry.(*protos.GetMapObjectsResponse).MapCells {
+			if len(cell.Forts) != 1 {
+				t.Fatalf("Expected the fort to be kept, got %d forts", len(cell.Forts))
+			}
+			if cell.Forts[0].LureInfo != nil {
+				lures++
+			}
+		}
+	}
+	if lures != 1 {
+		t.Errorf("Expected the lure to be forwarded once, got %d times", lures)
+	}
+	if lured.LureInfo == nil {
+		t.Error("Expected the fort itself not to be changed")
+	}
+
+	// A new lure on the same pokéstop is forwarded again
+	relured := &protos.FortData{Id: "pokestop", LureInfo: &protos.FortLureInfo{FortId: "pokestop", LureExpiresTimestampMs: 1500000}}
+	feed.Push(&protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{S2CellId: 1, Forts: []*protos.FortData{relured}}}})
+	last := record.entries[len(record.entries)-1].(*protos.GetMapObjectsResponse)
+	if last.MapCells[0].Forts[0].LureInfo == nil {
+		t.Error("Expected a new lure to be forwarded")
+	}
+}
+
+func TestDedupeFeedEntry(t *testing.T) {
+	record := &recordEntryFeed{}
+	feed := NewDedupeFeed(record, 10)
+
+	pidgey := &protos.WildPokemon{EncounterId: 1, TimeTillHiddenMs: 600000}
+	feed.PushEntry(&FeedEntry{Message: pidgey, Username: "ash", RequestType: protos.RequestType_GET_MAP_OBJECTS})
+	feed.PushEntry(&FeedEntry{Message: pidgey, Username: "ash"})
+
+	if len(record.entries) != 1 {
+		t.Fatalf("Expected the Pokémon to be forwarded once, got %d entries", len(record.entries))
+	}
+	if entry := record.entries[0]; entry.Username != "ash" || entry.RequestType != protos.RequestType_GET_MAP_OBJECTS {
+		t.Errorf("Expected the entry to keep its context, got %#v", entry)
+	}
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// Pokémon without a known despawn time are forgotten when not seen for this long
+const maxPokemonUnseen = time.Hour
+
+// Forts are forgotten when not seen for this long
+const maxFortUnseen = 24 * time.Hour
+
+// PokemonAppearedEvent is pushed when a wild Pokémon is seen for the first time
+type PokemonAppearedEvent struct {
+	EncounterID uint64
+	Pokemon     *SnapshotWildPokemon
+	Timestamp   time.Time
+}
+
+// PokemonDespawnedEvent is pushed when a wild Pokémon is gone from a scanned cell or its despawn time has passed
+type PokemonDespawnedEvent struct {
+	EncounterID uint64
+	Pokemon     *SnapshotWildPokemon
+	Timestamp   time.Time
+}
+
+// LureAddedEvent is pushed when a lure module is put on a pokéstop, or when a pokéstop is first seen with a lure module
+type LureAddedEvent struct {
+	FortID    string
+	Fort      *protos.FortData
+	Lure      *protos.FortLureInfo
+	Timestamp time.Time
+}
+
+// LureExpiredEvent is pushed when the lure module of a pokéstop is gone or has expired
+type LureExpiredEvent struct {
+	FortID    string
+	Fort      *protos.FortData
+	Lure      *protos.FortLureInfo
+	Timestamp time.Time
+}
+
+// GymChangedEvent is pushed when the owner team or the points of a gym have changed
+type GymChangedEvent struct {
+	FortID    string
+	Previous  *protos.FortData
+	Current   *protos.FortData
+	Timestamp time.Time
+}
+
+// FortCooldownEndedEvent is pushed when a pokéstop can be searched again
+type FortCooldownEndedEvent struct {
+	FortID    string
+	Fort      *protos.FortData
+	Timestamp time.Time
+}
+
+type trackedPokemon struct {
+	pokemon  *SnapshotWildPokemon
+	lastSeen time.Time
+}
+
+type trackedFort struct {
+	fort          *protos.FortData
+	lureExpired   bool
+	cooldownEnded bool
+	lastSeen      time.Time
+}
+
+type encounterIDs []uint64
+
+func (a encounterIDs) Len() int           { return len(a) }
+func (a encounterIDs) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a encounterIDs) Less(i, j int) bool { return a[i] < a[j] }
+
+// MapDiffer compares successive map objects responses and tells what has changed, it is not safe for concurrent use
+type MapDiffer struct {
+	pokemons map[uint64]*trackedPokemon
+	forts    map[string]*trackedFort
+	now      func() time.Time
+}
+
+// NewMapDiffer constructs a map differ without any previously seen map objects
+func NewMapDiffer() *MapDiffer {
+	return &MapDiffer{
+		pokemons: make(map[uint64]*trackedPokemon),
+		forts:    make(map[string]*trackedFort),
+		now:      time.Now,
+	}
+}
+
+func passed(ms int64, now time.Time) bool {
+	return ms > 0 && !now.Before(fromTimestampMs(ms))
+}
+
+// Diff returns the events describing the changes since the previous map objects. Events of objects that are not
+// in the response are ordered by encounter ID or fort ID.
+func (d *MapDiffer) Diff(mapObjects *protos.GetMapObjectsResponse) []interface{} {
+	return d.DiffAt(mapObjects, d.now())
+}
+
+// DiffAt returns the events describing the changes since the previous map objects like Diff, for map objects
+// received at the time, so recorded responses can be diffed as they were received
+func (d *MapDiffer) DiffAt(mapObjects *protos.GetMapObjectsResponse, now time.Time) []interface{} {
+	snapshot := NewMapSnapshot(mapObjects, &Location{}, now)
+	events := make([]interface{}, 0)
+
+	scanned := make(map[uint64]bool)
+	for _, cell := range mapObjects.GetMapCells() {
+		scanned[cell.S2CellId] = true
+	}
+
+	seen := make(map[uint64]bool)
+	for _, pokemon := range snapshot.WildPokemons {
+		encounterID := pokemon.Pokemon.EncounterId
+		seen[encounterID] = true
+		if _, ok := d.pokemons[encounterID]; !ok {
+			events = append(events, &PokemonAppearedEvent{
+				EncounterID: encounterID,
+				Pokemon:     pokemon,
+				Timestamp:   now,
+			})
+		}
+		d.pokemons[encounterID] = &trackedPokemon{pokemon: pokemon, lastSeen: now}
+	}
+	unseen := make(encounterIDs, 0)
+	for encounterID := range d.pokemons {
+		if !seen[encounterID] {
+			unseen = append(unseen, encounterID)
+		}
+	}
+	sort.Sort(unseen)
+	for _, encounterID := range unseen {
+		tracked := d.pokemons[encounterID]
+		despawn := tracked.pokemon.DespawnTime
+		if despawn.IsZero() && now.Sub(tracked.lastSeen) > maxPokemonUnseen {
+			delete(d.pokemons, encounterID)
+			continue
+		}
+		if scanned[tracked.pokemon.CellID] || (!despawn.IsZero() && !now.Before(despawn)) {
+			events = append(events, &PokemonDespawnedEvent{
+				EncounterID: encounterID,
+				Pokemon:     tracked.pokemon,
+				Timestamp:   now,
+			})
+			delete(d.pokemons, encounterID)
+		}
+	}
+
+	for _, snapshotFort := range snapshot.Forts {
+		fort := snapshotFort.Fort
+		tracked, ok := d.forts[fort.Id]
+		if !ok {
+			lureExpired := fort.LureInfo == nil || passed(fort.LureInfo.LureExpiresTimestampMs, now)
+			d.forts[fort.Id] = &trackedFort{
+				fort:          fort,
+				lureExpired:   lureExpired,
+				cooldownEnded: fort.CooldownCompleteTimestampMs == 0 || passed(fort.CooldownCompleteTimestampMs, now),
+				lastSeen:      now,
+			}
+			// Lures are added when first seen like Pokémon appear, the state of a fort is not an event until it changes
+			if !lureExpired {
+				events = append(events, &LureAddedEvent{FortID: fort.Id, Fort: fort, Lure: fort.LureInfo, Timestamp: now})
+			}
+			continue
+		}
+		events = append(events, tracked.update(fort, now)...)
+	}
+
+	fortIDs := make([]string, 0, len(d.forts))
+	for id := range d.forts {
+		fortIDs = append(fortIDs, id)
+	}
+	sort.Strings(fortIDs)
+
+	// Lures and cooldowns may run out without the fort being scanned again
+	for _, id := range fortIDs {
+		tracked := d.forts[id]
+		if now.Sub(tracked.lastSeen) > maxFortUnseen {
+			delete(d.forts, id)
+			continue
+		}
+		if !tracked.lureExpired && passed(tracked.fort.GetLureInfo().GetLureExpiresTimestampMs(), now) {
+			tracked.lureExpired = true
+			events = append(events, &LureExpiredEvent{FortID: id, Fort: tracked.fort, Lure: tracked.fort.LureInfo, Timestamp: now})
+		}
+		if !tracked.cooldownEnded && passed(tracked.fort.CooldownCompleteTimestampMs, now) {
+			tracked.cooldownEnded = true
+			events = append(events, &FortCooldownEndedEvent{FortID: id, Fort: tracked.fort, Timestamp: now})
+		}
+	}
+
+	return events
+}
+
+// update replaces the fort state and returns the events describing the changes
+func (t *trackedFort) update(fort *protos.FortData, now time.Time) []interface{} {
+	events := make([]interface{}, 0)
+	previous := t.fort
+
+	var previousLure *protos.FortLureInfo
+	if !t.lureExpired {
+		previousLure = previous.LureInfo
+	}
+	lure := fort.LureInfo
+	if lure != nil && passed(lure.LureExpiresTimestampMs, now) {
+		lure = nil
+	}
+	if previousLure != nil && (lure == nil || lure.LureExpiresTimestampMs != previousLure.LureExpiresTimestampMs) {
+		events = append(events, &LureExpiredEvent{FortID: fort.Id, Fort: fort, Lure: previousLure, Timestamp: now})
+	}
+	if lure != nil && (previousLure == nil || lure.LureExpiresTimestampMs != previousLure.LureExpiresTimestampMs) {
+		events = append(events, &LureAddedEvent{FortID: fort.Id, Fort: fort, Lure: lure, Timestamp: now})
+	}
+	t.lureExpired = lure == nil
+
+	if fort.Type == protos.FortType_GYM && (fort.OwnedByTeam != previous.OwnedByTeam || fort.GymPoints != previous.GymPoints) {
+		events = append(events, &GymChangedEvent{FortID: fort.Id, Previous: previous, Current: fort, Timestamp: now})
+	}
+
+	cooldownEnded := fort.CooldownCompleteTimestampMs == 0 || passed(fort.CooldownCompleteTimestampMs, now)
+	if !t.cooldownEnded && cooldownEnded {
+		events = append(events, &FortCooldownEndedEvent{FortID: fort.Id, Fort: fort, Timestamp: now})
+	}
+	t.cooldownEnded = cooldownEnded
+
+	t.fort = fort
+	t.lastSeen = now
+	return events
+}
+
+// DiffFeed is a feed decorator that pushes the events of what has changed between map objects responses
+// after the responses themselves, responses may be pushed concurrently
+type DiffFeed struct {
+	feed      Feed
+	entryFeed EntryFeed
+	differ    *MapDiffer
+	mutex     sync.Mutex
+}
+
+// NewDiffFeed constructs a diff feed pushing responses and events to the feed
+func NewDiffFeed(feed Feed) *DiffFeed {
+	return &DiffFeed{
+		feed:      feed,
+		entryFeed: toEntryFeed(feed),
+		differ:    NewMapDiffer(),
+	}
+}
+
+// diff returns the events of the map objects received at the time, or now when the time is not known
+func (f *DiffFeed) diff(mapObjects *protos.GetMapObjectsResponse, received time.Time) []interface{} {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if received.IsZero() {
+		return f.differ.Diff(mapObjects)
+	}
+	return f.differ.DiffAt(mapObjects, received)
+}
+
+// Push passes the entry on and pushes the events of a map objects response
+func (f *DiffFeed) Push(entry interface{}) {
+	f.feed.Push(entry)
+	if mapObjects, ok := entry.(*protos.GetMapObjectsResponse); ok {
+		for _, event := range f.diff(mapObjects, time.Time{}) {
+			f.feed.Push(event)
+		}
+	}
+}
+
+// PushEntry passes the entry on and pushes the events of a map objects response with the context of the response,
+// the response is diffed at the time it was received
+func (f *DiffFeed) PushEntry(entry *FeedEntry) {
+	f.entryFeed.PushEntry(entry)
+	if mapObjects, ok := entry.Message.(*protos.GetMapObjectsResponse); ok {
+		for _, event := range f.diff(mapObjects, entry.Timestamp) {
+			eventEntry := *entry
+			eventEntry.Message = event
+			f.entryFeed.PushEntry(&eventEntry)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+func TestMapDiffer(t *testing.T) {
+	now := time.Unix(1000, 0)
+	differ := NewMapDiffer()
+	differ.now = func() time.Time { return now }
+
+	pidgey := &protos.WildPokemon{EncounterId: 1, LastModifiedTimestampMs: 1000000, TimeTillHiddenMs: 600000}
+	gym := &protos.FortData{Id: "gym", Type: protos.FortType_GYM, OwnedByTeam: protos.TeamColor_BLUE, GymPoints: 1000}
+	pokestop := &protos.FortData{Id: "pokestop", Type: protos.FortType_CHECKPOINT, CooldownCompleteTimestampMs: 1300000}
+	cell := func(pokemons []*protos.WildPokemon, forts ...*protos.FortData) *protos.GetMapObjectsResponse {
+		return &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{S2CellId: 1, WildPokemons: pokemons, Forts: forts}}}
+	}
+
+	events := differ.Diff(cell([]*protos.WildPokemon{pidgey}, gym, pokestop))
+	if len(events) != 1 {
+		t.Fatalf("Expected only the Pokémon to appear, got %d events", len(events))
+	}
+	if e, ok := events[0].(*PokemonAppearedEvent); !ok || e.EncounterID != 1 {
+		t.Errorf("Expected Pokémon 1 to appear, got %#v", events[0])
+	}
+
+	now = now.Add(time.Minute)
+	lured := &protos.FortData{Id: "pokestop", Type: protos.FortType_CHECKPOINT, CooldownCompleteTimestampMs: 1300000,
+		LureInfo: &protos.FortLureInfo{FortId: "pokestop", LureExpiresTimestampMs: 1200000}}
+	taken := &protos.FortData{Id: "gym", Type: protos.FortType_GYM, OwnedByTeam: protos.TeamColor_RED, GymPoints: 2000}
+	events = differ.Diff(cell([]*protos.WildPokemon{pidgey}, taken, lured))
+	if len(events) != 2 {
+		t.Fatalf("Expected the gym to change and a lure to be added, got %d events", len(events))
+	}
+	if e, ok := events[0].(*GymChangedEvent); !ok || e.Previous.OwnedByTeam != protos.TeamColor_BLUE || e.Current.OwnedByTeam != protos.TeamColor_RED {
+		t.Errorf("Expected the gym to change team, got %#v", events[0])
+	}
+	if _, ok := events[1].(*LureAddedEvent); !ok {
+		t.Errorf("Expected a lure to be added, got %#v", events[1])
+	}
+
+	// Nothing is scanned, but the lure, the cooldown and the Pokémon run out
+	now = time.Unix(1700, 0)
+	events = differ.Diff(&protos.GetMapObjectsResponse{})
+	counts := make(map[string]int)
+	for _, event := range events {
+		switch event.(type) {
+		case *PokemonDespawnedEvent:
+			counts["despawned"]++
+		case *LureExpiredEvent:
+			counts["lure"]++
+		case *FortCooldownEndedEvent:
+			counts["cooldown"]++
+		default:
+			t.Errorf("Unexpected event %#v", event)
+		}
+	}
+	if counts["despawned"] != 1 || counts["lure"] != 1 || counts["cooldown"] != 1 {
+		t.Errorf("Expected a despawn, an expired lure and an ended cooldown, got %v", counts)
+	}
+
+	if events = differ.Diff(&protos.GetMapObjectsResponse{}); len(events) != 0 {
+		t.Errorf("Expected events to be pushed only once, got %d events", len(events))
+	}
+}
+
+func TestMapDifferFirstSeenLure(t *testing.T) {
+	differ := NewMapDiffer()
+	differ.now = func() time.Time { return time.Unix(1000, 0) }
+
+	lured := &protos.FortData{Id: "lured", Type: protos.FortType_CHECKPOINT,
+		LureInfo: &protos.FortLureInfo{FortId: "lured", LureExpiresTimestampMs: 1200000}}
+	expired := &protos.FortData{Id: "expired", Type: protos.FortType_CHECKPOINT,
+		LureInfo: &protos.FortLureInfo{FortId: "expired", LureExpiresTimestampMs: 900000}}
+	events := differ.Diff(&protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{S2CellId: 1, Forts: []*protos.FortData{lured, expired}}}})
+	if len(events) != 1 {
+		t.Fatalf("Expected only the active lure to be added, got %d events", len(events))
+	}
+	if e, ok := events[0].(*LureAddedEvent); !ok || e.FortID != "lured" {
+		t.Errorf("Expected the lure of the first seen pokéstop to be added, got %#v", events[0])
+	}
+}
+
+func TestMapDifferOrderAndExpiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+	differ := NewMapDiffer()
+	differ.now = func() time.Time { return now }
+
+	pokemons := make([]*protos.WildPokemon, 0)
+	forts := make([]*protos.FortData, 0)
+	for id := uint64(1); id <= 20; id++ {
+		pokemons = append(pokemons, &protos.WildPokemon{EncounterId: id, LastModifiedTimestampMs: 1000000, TimeTillHiddenMs: 600000})
+		forts = append(forts, &protos.FortData{Id: fmt.Sprintf("fort%02d", id), Type: protos.FortType_CHECKPOINT, CooldownCompleteTimestampMs: 1300000})
+	}
+	differ.Diff(&protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{S2CellId: 1, WildPokemons: pokemons, Forts: forts}}})
+
+	now = time.Unix(1700, 0)
+	events := differ.Diff(&protos.GetMapObjectsResponse{})
+	if len(events) != 40 {
+		t.Fatalf("Expected every Pokémon to despawn and every cooldown to end, got %d events", len(events))
+	}
+	for idx := 0; idx < 20; idx++ {
+		if e, ok := events[idx].(*PokemonDespawnedEvent); !ok || e.EncounterID != uint64(idx+1) {
+			t.Errorf("Expected Pokémon %d to despawn, got %#v", idx+1, events[idx])
+		}
+		if e, ok := events[20+idx].(*FortCooldownEndedEvent); !ok || e.FortID != fmt.Sprintf("fort%02d", idx+1) {
+			t.Errorf("Expected the cooldown of fort %d to end, got %#v", idx+1, events[20+idx])
+		}
+	}
+
+	now = now.Add(maxFortUnseen + time.Second)
+	differ.Diff(&protos.GetMapObjectsResponse{})
+	if len(differ.forts) != 0 {
+		t.Errorf("Expected forts not seen for a day to be forgotten, got %d forts", len(differ.forts))
+	}
+}
+
+func TestDiffFeedEntry(t *testing.T) {
+	record := &recordEntryFeed{}
+	feed := NewDiffFeed(record)
+	cell := &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{
+		S2CellId:     1,
+		WildPokemons: []*protos.WildPokemon{{EncounterId: 1, TimeTillHiddenMs: 600000}},
+	}}}
+	feed.PushEntry(&FeedEntry{Message: cell, Username: "ash", RequestID: 7})
+
+	if len(record.entries) != 2 {
+		t.Fatalf("Expected the response and an event, got %d entries", len(record.entries))
+	}
+	event := record.entries[1]
+	if _, ok := event.Message.(*PokemonAppearedEvent); !ok || event.Username != "ash" || event.RequestID != 7 {
+		t.Errorf("Expected the event with the context of the response, got %#v", event)
+	}
+}
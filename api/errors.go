@@ -37,6 +37,27 @@ var ErrRequest = errors.New("The remote service responded but the request could
 // ErrNoURL happens when the remote service is expected to respond with a remote URL but doesn't
 var ErrNoURL = errors.New("The remote service did not respond with a remote URL when expected")
 
+// ErrEmptyResponse happens when the remote service responds without the expected return values
+var ErrEmptyResponse = errors.New("The remote service responded without the expected return values")
+
+// ErrCodenameUnavailable happens when the requested trainer name is missing or cannot be claimed
+var ErrCodenameUnavailable = errors.New("The trainer name is missing or not available")
+
+// ErrInvalidLocation happens when the coordinates of a location are not a number or out of range
+var ErrInvalidLocation = errors.New("The location coordinates are invalid")
+
+// ErrInvalidBoundingBox happens when a bounding box is not given as four comma separated coordinates
+var ErrInvalidBoundingBox = errors.New("The bounding box is not given as minLat,minLon,maxLat,maxLon")
+
+// ErrInvalidCellLevel happens when a map cell level is not between 0 and 30
+var ErrInvalidCellLevel = errors.New("The cell level is not between 0 and 30")
+
+// ErrInvalidScanArea happens when the scan radius is negative or covers too many map cells at the cell level
+var ErrInvalidScanArea = errors.New("The scan radius is invalid or covers too many map cells at the cell level")
+
+// ErrTooManyCells happens when a region is covered with too many map cells at the cell level
+var ErrTooManyCells = errors.New("The region is covered with too many map cells at the cell level")
+
 // GetErrorFromStatus will, depending on the status code, give you an error or nil if there is no error
 func GetErrorFromStatus(status protos.ResponseEnvelope_StatusCode) error {
 	switch status {
@@ -69,3 +90,17 @@ type ErrResponse struct {
 func (e *ErrResponse) Error() string {
 	return fmt.Sprintf("The response could not be read: %s", e.err.Error())
 }
+
+// ErrChallengeRequired happens when the remote service requires a captcha challenge to be solved
+type ErrChallengeRequired struct {
+	URL string
+}
+
+func (e *ErrChallengeRequired) Error() string {
+	return fmt.Sprintf("A challenge needs to be solved before continuing: %s", e.URL)
+}
+
+// isFailure tells if an error returned from a request should stop further requests
+func isFailure(err error) bool {
+	return err != nil && err != ErrNewRPCURL
+}
@@ -1,5 +1,11 @@
 package api
 
+import (
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
 // Feed is a common interface to act on encountered
 type Feed interface {
 	// Push is used to put response messages on to the feed
@@ -14,3 +20,76 @@ type VoidFeed struct {
 func (f *VoidFeed) Push(entry interface{}) {
 	// NOOP
 }
+
+// FeedEntry is a message pushed by a session together with the context it was received in
+type FeedEntry struct {
+	Message interface{}
+
+	// Provider and Username identify the account of the session, the username is empty when the
+	// provider cannot tell it
+	Provider string
+	Username string
+
+	Location    Location
+	RequestType protos.RequestType
+	RequestID   uint64
+	StatusCode  protos.ResponseEnvelope_StatusCode
+
+	// Timestamp is when the response was received
+	Timestamp time.Time
+	// ServerTimestamp is the time reported by the remote service in the message, or the zero time
+	ServerTimestamp time.Time
+	// TicketExpiry is when the auth ticket of the response expires, or the zero time
+	TicketExpiry time.Time
+}
+
+// EntryFeed is a richer feed receiving the messages together with their context
+type EntryFeed interface {
+	// PushEntry is used to put response messages with their context on to the feed
+	PushEntry(entry *FeedEntry)
+}
+
+// FeedAdapter is an entry feed pushing only the messages on to a feed
+type FeedAdapter struct {
+	feed Feed
+}
+
+// NewFeedAdapter constructs an entry feed for the feed
+func NewFeedAdapter(feed Feed) *FeedAdapter {
+	return &FeedAdapter{feed: feed}
+}
+
+// PushEntry pushes the message of the entry
+func (a *FeedAdapter) PushEntry(entry *FeedEntry) {
+	a.feed.Push(entry.Message)
+}
+
+// Push pushes the entry as is
+func (a *FeedAdapter) Push(entry interface{}) {
+	a.feed.Push(entry)
+}
+
+// toEntryFeed returns the feed as an entry feed, adapting it if it only receives messages
+func toEntryFeed(feed Feed) EntryFeed {
+	if entryFeed, ok := feed.(EntryFeed); ok {
+		return entryFeed
+	}
+	return NewFeedAdapter(feed)
+}
+
+// getServerTimestamp returns the time reported by the remote service in the message, or the zero time
+func getServerTimestamp(message interface{}) time.Time {
+	switch m := message.(type) {
+	case *protos.GetMapObjectsResponse:
+		for _, cell := range m.GetMapCells() {
+			if cell.CurrentTimestampMs > 0 {
+				return fromTimestampMs(cell.CurrentTimestampMs)
+			}
+		}
+	case *protos.GetInventoryResponse:
+		if delta := m.GetInventoryDelta(); delta != nil && delta.NewTimestampMs > 0 {
+			return fromTimestampMs(delta.NewTimestampMs)
+		}
+	}
+	return time.Time{}
+}
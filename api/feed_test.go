@@ -0,0 +1,60 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+	"github.com/pogodevorg/pgoapi-go/auth"
+)
+
+type recordEntryFeed struct {
+	entries []*FeedEntry
+}
+
+func (f *recordEntryFeed) PushEntry(entry *FeedEntry) {
+	f.entries = append(f.entries, entry)
+}
+
+func (f *recordEntryFeed) Push(entry interface{}) {
+	f.PushEntry(&FeedEntry{Message: entry})
+}
+
+func TestSessionPush(t *testing.T) {
+	record := &recordFeed{}
+	s := NewSession(&auth.UnknownProvider{}, &Location{Lat: 1, Lon: 2}, record, nil, false)
+
+	response := &envelope{
+		ResponseEnvelope: &protos.ResponseEnvelope{
+			RequestId:  42,
+			StatusCode: protos.ResponseEnvelope_OK,
+			AuthTicket: &protos.AuthTicket{ExpireTimestampMs: 2000000},
+		},
+		requests: []*protos.Request{
+			{RequestType: protos.RequestType_GET_PLAYER},
+			{RequestType: protos.RequestType_GET_MAP_OBJECTS},
+		},
+		location: Location{Lat: 1, Lon: 2},
+		received: time.Unix(1000, 0),
+	}
+	mapObjects := &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{CurrentTimestampMs: 1000500}}}
+
+	s.push(response, 1, mapObjects)
+	if len(record.entries) != 1 || record.entries[0] != mapObjects {
+		t.Fatalf("Expected the message to be pushed to the plain feed, got %v", record.entries)
+	}
+
+	entries := &recordEntryFeed{}
+	s.SetEntryFeed(entries)
+	s.push(response, 1, mapObjects)
+	if len(entries.entries) != 1 {
+		t.Fatalf("Expected one entry, got %d", len(entries.entries))
+	}
+	entry := entries.entries[0]
+	if entry.RequestType != protos.RequestType_GET_MAP_OBJECTS || entry.RequestID != 42 || entry.Location.Lat != 1 {
+		t.Errorf("Expected the entry to carry the context of the response, got %#v", entry)
+	}
+	if !entry.ServerTimestamp.Equal(time.Unix(1000, 500000000)) || !entry.TicketExpiry.Equal(time.Unix(2000, 0)) {
+		t.Errorf("Expected the server timestamps to be decoded, got %v and %v", entry.ServerTimestamp, entry.TicketExpiry)
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// GetGymDetails returns the gym state, including the owner team, gym points and defending Pokémon
+func (s *Session) GetGymDetails(ctx context.Context, gym *protos.FortData) (*protos.GetGymDetailsResponse, error) {
+	message := &protos.GetGymDetailsMessage{
+		GymId:           gym.Id,
+		PlayerLatitude:  s.location.Lat,
+		PlayerLongitude: s.location.Lon,
+		GymLatitude:     gym.Latitude,
+		GymLongitude:    gym.Longitude,
+		ClientVersion:   clientVersion,
+	}
+	response, err := s.callRequest(ctx, protos.RequestType_GET_GYM_DETAILS, message)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &protos.GetGymDetailsResponse{}
+	err = s.decodeReturn(response, 0, details)
+	if err != nil {
+		return nil, err
+	}
+
+	return details, GetErrorFromStatus(response.StatusCode)
+}
+
+// DeployPokemon puts one of the player's Pokémon in a gym to defend it
+func (s *Session) DeployPokemon(ctx context.Context, fortID string, pokemonID uint64) (*protos.FortDeployPokemonResponse, error) {
+	message := &protos.FortDeployPokemonMessage{
+		FortId:          fortID,
+		PokemonId:       pokemonID,
+		PlayerLatitude:  s.location.Lat,
+		PlayerLongitude: s.location.Lon,
+	}
+	response, err := s.callRequest(ctx, protos.RequestType_FORT_DEPLOY_POKEMON, message)
+	if err != nil {
+		return nil, err
+	}
+
+	deployed := &protos.FortDeployPokemonResponse{}
+	err = s.decodeReturn(response, 0, deployed)
+	if err != nil {
+		return nil, err
+	}
+
+	return deployed, GetErrorFromStatus(response.StatusCode)
+}
+
+// RecallPokemon takes one of the player's Pokémon back from a gym
+func (s *Session) RecallPokemon(ctx context.Context, fortID string, pokemonID uint64) (*protos.FortRecallPokemonResponse, error) {
+	message := &protos.FortRecallPokemonMessage{
+		FortId:          fortID,
+		PokemonId:       pokemonID,
+		PlayerLatitude:  s.location.Lat,
+		PlayerLongitude: s.location.Lon,
+	}
+	response, err := s.callRequest(ctx, protos.RequestType_FORT_RECALL_POKEMON, message)
+	if err != nil {
+		return nil, err
+	}
+
+	recalled := &protos.FortRecallPokemonResponse{}
+	err = s.decodeReturn(response, 0, recalled)
+	if err != nil {
+		return nil, err
+	}
+
+	return recalled, GetErrorFromStatus(response.StatusCode)
+}
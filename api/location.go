@@ -3,13 +3,24 @@ package api
 import (
 	"encoding/binary"
 	"math"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/golang/geo/s1"
 	"github.com/golang/geo/s2"
 	protos "github.com/pogodevorg/POGOProtos-go"
 )
 
 const cellIDLevel = 15
-const earthRadiusInMeters = 6378100
+
+// EarthRadiusInMeters is the radius of the earth used for distances between locations
+const EarthRadiusInMeters = 6378100
+
+const maxCellLevel = 30
+
+// MaxCoverCells is the most map cells a region may be estimated to be covered with
+const MaxCoverCells = 10000
 
 // CellIDs is a slice of uint64s
 type CellIDs []uint64
@@ -18,7 +29,10 @@ func (a CellIDs) Len() int           { return len(a) }
 func (a CellIDs) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a CellIDs) Less(i, j int) bool { return a[i] < a[j] }
 
-// Location consists of coordinates in longitude, latitude and altitude
+// Location consists of coordinates in longitude, latitude and altitude.
+//
+// The distance, bearing, destination, midpoint and bounding box helpers do not validate the coordinates. Callers
+// must check locations from untrusted input with Validate first, invalid coordinates give meaningless results.
 type Location struct {
 	Lon      float64
 	Lat      float64
@@ -39,23 +53,243 @@ func (l *Location) GetCellIDs() CellIDs {
 	return cellIDs
 }
 
-// DistanceToFort returns distance between the location and a fort using the Haversine formula
+// GetCellIDsInRadius will return a sorted slice of all cell ids at the cell level within the radius in meters
+func (l *Location) GetCellIDsInRadius(radius float64, level int) (CellIDs, error) {
+	err := ValidateScanArea(radius, level)
+	if err != nil {
+		return nil, err
+	}
+	center := s2.PointFromLatLng(s2.LatLngFromDegrees(l.Lat, l.Lon))
+	return CoverRegion(s2.CapFromCenterAngle(center, s1.Angle(radius/EarthRadiusInMeters)), level)
+}
+
+// ValidateCellLevel tells if map cells can be requested at the cell level
+func ValidateCellLevel(level int) error {
+	if level < 0 || level > maxCellLevel {
+		return ErrInvalidCellLevel
+	}
+	return nil
+}
+
+// ValidateScanArea tells if the map cells at the cell level within the radius in meters can be requested
+func ValidateScanArea(radius float64, level int) error {
+	err := ValidateCellLevel(level)
+	if err != nil {
+		return err
+	}
+	if math.IsNaN(radius) || radius < 0 {
+		return ErrInvalidScanArea
+	}
+
+	angle := math.Min(radius/EarthRadiusInMeters, math.Pi)
+	if estimateCells(2*math.Pi*(1-math.Cos(angle)), level) > MaxCoverCells {
+		return ErrInvalidScanArea
+	}
+	return nil
+}
+
+// estimateCells estimates the number of map cells at the cell level covering an area in steradians from
+// the average area of a cell
+func estimateCells(area float64, level int) float64 {
+	return area / s2.AvgAreaMetric.Value(level)
+}
+
+// CoverRegion will return a sorted slice of all cell ids at the cell level covering the region. The error is
+// ErrTooManyCells when the region is estimated to be covered with more than MaxCoverCells cells, the region
+// coverer does not limit the number of cells when all cells are at the same level.
+func CoverRegion(region s2.Region, level int) (CellIDs, error) {
+	err := ValidateCellLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	area := math.Min(region.CapBound().Area(), region.RectBound().Area())
+	if estimateCells(area, level) > MaxCoverCells {
+		return nil, ErrTooManyCells
+	}
+
+	coverer := &s2.RegionCoverer{
+		MinLevel: level,
+		MaxLevel: level,
+		LevelMod: 1,
+	}
+
+	var cellIDs = make(CellIDs, 0)
+	for _, cellID := range coverer.Covering(region) {
+		// Cells of a lower level are expanded in to their children at the requested level
+		for child := cellID.ChildBeginAtLevel(level); child != cellID.ChildEndAtLevel(level); child = child.Next() {
+			cellIDs = append(cellIDs, uint64(child))
+		}
+	}
+
+	sort.Sort(cellIDs)
+
+	unique := cellIDs[:0]
+	for _, cellID := range cellIDs {
+		if len(unique) == 0 || cellID != unique[len(unique)-1] {
+			unique = append(unique, cellID)
+		}
+	}
+
+	return unique, nil
+}
+
+// Validate returns an error if the coordinates are not a number or out of range
+func (l *Location) Validate() error {
+	if math.IsNaN(l.Lat) || math.IsNaN(l.Lon) || math.IsNaN(l.Alt) || math.IsNaN(l.Accuracy) {
+		return ErrInvalidLocation
+	}
+	if l.Lat < -90 || l.Lat > 90 || l.Lon < -180 || l.Lon > 180 {
+		return ErrInvalidLocation
+	}
+	return nil
+}
+
+// DistanceToCoordinates returns distance in meters between the location and a coordinate using the Haversine formula
 // Reference: https://gist.github.com/cdipaolo/d3f8db3848278b49db68
-func (l *Location) DistanceToFort(fort *protos.FortData) float64 {
+func (l *Location) DistanceToCoordinates(lat, lon float64) float64 {
 	// convert to radians
 	// must cast radius as float to multiply later
 	var la1, lo1, la2, lo2 float64
-	la1 = l.Lat * math.Pi / 180
-	lo1 = l.Lon * math.Pi / 180
-	la2 = fort.Latitude * math.Pi / 180
-	lo2 = fort.Longitude * math.Pi / 180
+	la1 = toRadians(l.Lat)
+	lo1 = toRadians(l.Lon)
+	la2 = toRadians(lat)
+	lo2 = toRadians(lon)
 
 	// calculate
 	dla := math.Sin(0.5 * (la2 - la1))
 	dlo := math.Sin(0.5 * (lo2 - lo1))
 	h := dla*dla + math.Cos(la1)*math.Cos(la2)*dlo*dlo
 
-	return 2 * earthRadiusInMeters * math.Asin(math.Sqrt(h))
+	return 2 * EarthRadiusInMeters * math.Asin(math.Sqrt(h))
+}
+
+// DistanceToLocation returns distance in meters between the location and another location
+func (l *Location) DistanceToLocation(other *Location) float64 {
+	return l.DistanceToCoordinates(other.Lat, other.Lon)
+}
+
+// DistanceToFort returns distance in meters between the location and a fort
+func (l *Location) DistanceToFort(fort *protos.FortData) float64 {
+	return l.DistanceToCoordinates(fort.Latitude, fort.Longitude)
+}
+
+// BearingTo returns the initial bearing in degrees, clockwise from north, of the great circle path to another location
+// Reference: http://www.movable-type.co.uk/scripts/latlong.html
+func (l *Location) BearingTo(other *Location) float64 {
+	la1 := toRadians(l.Lat)
+	la2 := toRadians(other.Lat)
+	dlo := toRadians(other.Lon - l.Lon)
+
+	y := math.Sin(dlo) * math.Cos(la2)
+	x := math.Cos(la1)*math.Sin(la2) - math.Sin(la1)*math.Cos(la2)*math.Cos(dlo)
+
+	return math.Mod(toDegrees(math.Atan2(y, x))+360, 360)
+}
+
+// Destination returns the location reached by travelling a distance in meters along a great circle from an initial bearing in degrees
+func (l *Location) Destination(bearing, distance float64) *Location {
+	la1 := toRadians(l.Lat)
+	lo1 := toRadians(l.Lon)
+	b := toRadians(bearing)
+	d := distance / EarthRadiusInMeters
+
+	la2 := math.Asin(math.Sin(la1)*math.Cos(d) + math.Cos(la1)*math.Sin(d)*math.Cos(b))
+	lo2 := lo1 + math.Atan2(math.Sin(b)*math.Sin(d)*math.Cos(la1), math.Cos(d)-math.Sin(la1)*math.Sin(la2))
+
+	return &Location{
+		Lat:      toDegrees(la2),
+		Lon:      normalizeLongitude(toDegrees(lo2)),
+		Alt:      l.Alt,
+		Accuracy: l.Accuracy,
+	}
+}
+
+// MidpointTo returns the location half way along the great circle path to another location
+func (l *Location) MidpointTo(other *Location) *Location {
+	la1 := toRadians(l.Lat)
+	lo1 := toRadians(l.Lon)
+	la2 := toRadians(other.Lat)
+	dlo := toRadians(other.Lon - l.Lon)
+
+	bx := math.Cos(la2) * math.Cos(dlo)
+	by := math.Cos(la2) * math.Sin(dlo)
+
+	la3 := math.Atan2(math.Sin(la1)+math.Sin(la2), math.Sqrt((math.Cos(la1)+bx)*(math.Cos(la1)+bx)+by*by))
+	lo3 := lo1 + math.Atan2(by, math.Cos(la1)+bx)
+
+	return &Location{
+		Lat:      toDegrees(la3),
+		Lon:      normalizeLongitude(toDegrees(lo3)),
+		Alt:      (l.Alt + other.Alt) / 2,
+		Accuracy: math.Max(l.Accuracy, other.Accuracy),
+	}
+}
+
+// BoundingBox is an area limited by minimum and maximum coordinates, the minimum longitude is larger than
+// the maximum longitude when the box crosses the antimeridian
+type BoundingBox struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+// Contains tells if the location is within the bounding box
+func (b *BoundingBox) Contains(l *Location) bool {
+	if l.Lat < b.MinLat || l.Lat > b.MaxLat {
+		return false
+	}
+	if b.MinLon <= b.MaxLon {
+		return l.Lon >= b.MinLon && l.Lon <= b.MaxLon
+	}
+	return l.Lon >= b.MinLon || l.Lon <= b.MaxLon
+}
+
+// ParseBoundingBox parses a bounding box given as "minLat,minLon,maxLat,maxLon"
+func ParseBoundingBox(box string) (*BoundingBox, error) {
+	parts := strings.Split(box, ",")
+	if len(parts) != 4 {
+		return nil, ErrInvalidBoundingBox
+	}
+	values := make([]float64, 4)
+	for idx, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, ErrInvalidBoundingBox
+		}
+		values[idx] = value
+	}
+	return &BoundingBox{MinLat: values[0], MinLon: values[1], MaxLat: values[2], MaxLon: values[3]}, nil
+}
+
+// BoundingBox returns the smallest bounding box containing all locations within the radius in meters, the radius
+// must not be negative
+// Reference: http://janmatuschek.de/LatitudeLongitudeBoundingCoordinates
+func (l *Location) BoundingBox(radius float64) *BoundingBox {
+	la := toRadians(l.Lat)
+	d := radius / EarthRadiusInMeters
+
+	minLat := la - d
+	maxLat := la + d
+
+	// The box covers all longitudes when a pole is within the radius
+	if minLat <= -math.Pi/2 || maxLat >= math.Pi/2 {
+		return &BoundingBox{
+			MinLat: toDegrees(math.Max(minLat, -math.Pi/2)),
+			MinLon: -180,
+			MaxLat: toDegrees(math.Min(maxLat, math.Pi/2)),
+			MaxLon: 180,
+		}
+	}
+
+	dlo := toDegrees(math.Asin(math.Sin(d) / math.Cos(la)))
+
+	return &BoundingBox{
+		MinLat: toDegrees(minLat),
+		MinLon: normalizeLongitude(l.Lon - dlo),
+		MaxLat: toDegrees(maxLat),
+		MaxLon: normalizeLongitude(l.Lon + dlo),
+	}
 }
 
 // GetBytes returns a byte slice of the location coordinates
@@ -66,3 +300,20 @@ func (l *Location) GetBytes() []byte {
 	binary.BigEndian.PutUint64(b[16:24], math.Float64bits(l.Accuracy))
 	return b[:24]
 }
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func toDegrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+// normalizeLongitude wraps a longitude in degrees in to the range from -180 to 180
+func normalizeLongitude(lon float64) float64 {
+	lon = math.Mod(lon+180, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	return lon - 180
+}
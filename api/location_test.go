@@ -1,6 +1,12 @@
 package api
 
-import "testing"
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/geo/s1"
+	"github.com/golang/geo/s2"
+)
 
 func BenchmarkGetBytes(b *testing.B) {
 	l := Location{0.0, 0.0, 0.0, 0.0}
@@ -8,3 +14,139 @@ func BenchmarkGetBytes(b *testing.B) {
 		l.GetBytes()
 	}
 }
+
+func TestGetCellIDsInRadius(t *testing.T) {
+	l := Location{Lat: 59.3293, Lon: 18.0686}
+	origin := s2.CellIDFromLatLng(s2.LatLngFromDegrees(l.Lat, l.Lon)).Parent(cellIDLevel)
+
+	cellIDs, err := l.GetCellIDsInRadius(500, cellIDLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cellIDs) < 5 {
+		t.Fatalf("Expected at least 5 cells, got %d", len(cellIDs))
+	}
+
+	hasOrigin := false
+	for idx, cellID := range cellIDs {
+		if s2.CellID(cellID).Level() != cellIDLevel {
+			t.Errorf("Expected cell %d to be at level %d", cellID, cellIDLevel)
+		}
+		if idx > 0 && cellIDs[idx-1] >= cellID {
+			t.Errorf("Expected cells to be sorted and unique, %d came before %d", cellIDs[idx-1], cellID)
+		}
+		if cellID == uint64(origin) {
+			hasOrigin = true
+		}
+	}
+	if !hasOrigin {
+		t.Error("Expected the cell of the location to be included")
+	}
+}
+
+func TestValidateScanArea(t *testing.T) {
+	if err := ValidateScanArea(500, cellIDLevel); err != nil {
+		t.Errorf("Expected a 500 meter radius at level %d to be valid, got %s", cellIDLevel, err)
+	}
+	for _, level := range []int{-1, 31} {
+		if err := ValidateScanArea(500, level); err != ErrInvalidCellLevel {
+			t.Errorf("Expected level %d to be invalid, got %v", level, err)
+		}
+	}
+	if err := ValidateScanArea(100000, 20); err != ErrInvalidScanArea {
+		t.Errorf("Expected a 100 km radius at level 20 to cover too many cells, got %v", err)
+	}
+	if err := ValidateScanArea(-1, cellIDLevel); err != ErrInvalidScanArea {
+		t.Errorf("Expected a negative radius to be invalid, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []Location{{Lat: 0, Lon: 0}, {Lat: -90, Lon: 180}, {Lat: 90, Lon: -180}}
+	for _, l := range valid {
+		if err := l.Validate(); err != nil {
+			t.Errorf("Expected %v to be valid, got %s", l, err)
+		}
+	}
+
+	invalid := []Location{{Lat: 91, Lon: 0}, {Lat: 0, Lon: -181}, {Lat: math.NaN(), Lon: 0}, {Lat: 0, Lon: 0, Alt: math.NaN()}}
+	for _, l := range invalid {
+		if err := l.Validate(); err != ErrInvalidLocation {
+			t.Errorf("Expected %v to be invalid", l)
+		}
+	}
+}
+
+func TestDistanceAndBearing(t *testing.T) {
+	stockholm := &Location{Lat: 59.3293, Lon: 18.0686}
+	gothenburg := &Location{Lat: 57.7089, Lon: 11.9746}
+
+	distance := stockholm.DistanceToLocation(gothenburg)
+	if math.Abs(distance-398000) > 2000 {
+		t.Errorf("Expected distance to be about 398 km, got %f", distance)
+	}
+
+	origin := &Location{Lat: 0, Lon: 0}
+	if bearing := origin.BearingTo(&Location{Lat: 0, Lon: 1}); math.Abs(bearing-90) > 1e-9 {
+		t.Errorf("Expected bearing due east to be 90 degrees, got %f", bearing)
+	}
+	if bearing := origin.BearingTo(&Location{Lat: -1, Lon: 0}); math.Abs(bearing-180) > 1e-9 {
+		t.Errorf("Expected bearing due south to be 180 degrees, got %f", bearing)
+	}
+
+	bearing := stockholm.BearingTo(gothenburg)
+
+	destination := stockholm.Destination(bearing, distance)
+	if destination.DistanceToLocation(gothenburg) > 1 {
+		t.Errorf("Expected destination %v to be at %v", destination, gothenburg)
+	}
+
+	midpoint := stockholm.MidpointTo(gothenburg)
+	if math.Abs(midpoint.DistanceToLocation(stockholm)-midpoint.DistanceToLocation(gothenburg)) > 1 {
+		t.Errorf("Expected midpoint %v to be as far from both locations", midpoint)
+	}
+}
+
+func TestBoundingBox(t *testing.T) {
+	l := &Location{Lat: 0, Lon: 179.999}
+	box := l.BoundingBox(1000)
+
+	if box.MinLon < box.MaxLon {
+		t.Errorf("Expected box %v to cross the antimeridian", box)
+	}
+	for _, bearing := range []float64{0, 90, 180, 270} {
+		if p := l.Destination(bearing, 999); !box.Contains(p) {
+			t.Errorf("Expected box %v to contain %v", box, p)
+		}
+		if p := l.Destination(bearing, 1100); box.Contains(p) {
+			t.Errorf("Expected box %v not to contain %v", box, p)
+		}
+	}
+}
+
+func TestNormalizeLongitude(t *testing.T) {
+	for lon, expected := range map[float64]float64{
+		0:    0,
+		190:  -170,
+		-190: 170,
+		540:  -180,
+		-600: 120,
+		900:  -180,
+		-900: -180,
+	} {
+		if normalized := normalizeLongitude(lon); math.Abs(normalized-expected) > 1e-9 {
+			t.Errorf("Expected longitude %f to be normalized to %f, got %f", lon, expected, normalized)
+		}
+	}
+}
+
+func TestCoverRegionTooMany(t *testing.T) {
+	center := s2.PointFromLatLng(s2.LatLngFromDegrees(59.3293, 18.0686))
+	region := s2.CapFromCenterAngle(center, s1.Angle(20000.0/EarthRadiusInMeters))
+	if _, err := CoverRegion(region, 17); err != ErrTooManyCells {
+		t.Errorf("Expected too many cells at level 17, got %v", err)
+	}
+	if cellIDs, err := CoverRegion(region, 10); err != nil || len(cellIDs) == 0 {
+		t.Errorf("Expected the region to be covered at level 10, got %d cells and %v", len(cellIDs), err)
+	}
+}
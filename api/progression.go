@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// LevelUpRewards collects the items awarded for reaching a player level
+func (s *Session) LevelUpRewards(ctx context.Context, level int32) (*protos.LevelUpRewardsResponse, error) {
+	message := &protos.LevelUpRewardsMessage{
+		Level: level,
+	}
+	response, err := s.callRequest(ctx, protos.RequestType_LEVEL_UP_REWARDS, message)
+	if err != nil {
+		return nil, err
+	}
+
+	rewards := &protos.LevelUpRewardsResponse{}
+	err = s.decodeReturn(response, 0, rewards)
+	if err != nil {
+		return nil, err
+	}
+
+	return rewards, GetErrorFromStatus(response.StatusCode)
+}
+
+// EquipBadge shows a badge on the player profile
+func (s *Session) EquipBadge(ctx context.Context, badge protos.BadgeType) (*protos.EquipBadgeResponse, error) {
+	message := &protos.EquipBadgeMessage{
+		BadgeType: badge,
+	}
+	response, err := s.callRequest(ctx, protos.RequestType_EQUIP_BADGE, message)
+	if err != nil {
+		return nil, err
+	}
+
+	equipped := &protos.EquipBadgeResponse{}
+	err = s.decodeReturn(response, 0, equipped)
+	if err != nil {
+		return nil, err
+	}
+
+	return equipped, GetErrorFromStatus(response.StatusCode)
+}
+
+// CollectDailyBonus collects the daily bonus of the player
+func (s *Session) CollectDailyBonus(ctx context.Context) (*protos.CollectDailyBonusResponse, error) {
+	response, err := s.callRequest(ctx, protos.RequestType_COLLECT_DAILY_BONUS, &protos.CollectDailyBonusMessage{})
+	if err != nil {
+		return nil, err
+	}
+
+	bonus := &protos.CollectDailyBonusResponse{}
+	err = s.decodeReturn(response, 0, bonus)
+	if err != nil {
+		return nil, err
+	}
+
+	return bonus, GetErrorFromStatus(response.StatusCode)
+}
+
+// SetBuddyPokemon makes one of the player's Pokémon walk along as a buddy
+func (s *Session) SetBuddyPokemon(ctx context.Context, pokemonID uint64) (*protos.SetBuddyPokemonResponse, error) {
+	message := &protos.SetBuddyPokemonMessage{
+		PokemonId: pokemonID,
+	}
+	response, err := s.callRequest(ctx, protos.RequestType_SET_BUDDY_POKEMON, message)
+	if err != nil {
+		return nil, err
+	}
+
+	buddy := &protos.SetBuddyPokemonResponse{}
+	err = s.decodeReturn(response, 0, buddy)
+	if err != nil {
+		return nil, err
+	}
+
+	return buddy, GetErrorFromStatus(response.StatusCode)
+}
+
+// GetBuddyWalked returns the candies earned by walking with the buddy Pokémon
+func (s *Session) GetBuddyWalked(ctx context.Context) (*protos.GetBuddyWalkedResponse, error) {
+	response, err := s.callRequest(ctx, protos.RequestType_GET_BUDDY_WALKED, &protos.GetBuddyWalkedMessage{})
+	if err != nil {
+		return nil, err
+	}
+
+	walked := &protos.GetBuddyWalkedResponse{}
+	err = s.decodeReturn(response, 0, walked)
+	if err != nil {
+		return nil, err
+	}
+
+	return walked, GetErrorFromStatus(response.StatusCode)
+}
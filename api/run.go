@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const minRunInterval = time.Second
+const maxRunBackoff = 5 * time.Minute
+
+// Tickets are refreshed this long before they expire
+const ticketExpiryMargin = time.Minute
+
+// ticketExpired tells if the auth ticket is missing or about to expire, a ticket without an expiry is kept
+// until the server rejects it
+func (s *Session) ticketExpired() bool {
+	if !s.hasTicket || s.ticket == nil {
+		return true
+	}
+	if s.ticket.ExpireTimestampMs == 0 {
+		return false
+	}
+	expires := fromTimestampMs(int64(s.ticket.ExpireTimestampMs))
+	return time.Now().Add(ticketExpiryMargin).After(expires)
+}
+
+// clearTicket drops the auth ticket and the RPC URL so the next call logs in again at the default URL
+func (s *Session) clearTicket() {
+	s.hasTicket = false
+	s.ticket = nil
+	s.url = ""
+}
+
+// getRefreshInterval returns the interval, or the minimum refresh interval of the map settings when it is longer
+func (s *Session) getRefreshInterval(interval time.Duration) time.Duration {
+	if s.mapSettings == nil {
+		return interval
+	}
+	minimum := time.Duration(s.mapSettings.GetMapObjectsMinRefreshSeconds * float32(time.Second))
+	if minimum > interval {
+		return minimum
+	}
+	return interval
+}
+
+func (s *Session) debugError(label string, err error) {
+	if s.debug {
+		log.Printf("%s: %s", label, err)
+	}
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Run announces the player at the current location in intervals until the context is done, pushing all
+// responses to the feed. The session is initialized again when the auth ticket expires or is rejected, and
+// failing requests are retried with an increasing delay. It returns early if a challenge needs to be solved.
+func (s *Session) Run(ctx context.Context, interval time.Duration) error {
+	if interval < minRunInterval {
+		interval = minRunInterval
+	}
+
+	backoff := interval
+	for {
+		var err error
+		if s.ticketExpired() {
+			s.clearTicket()
+			err = s.Init(ctx)
+		}
+		if !isFailure(err) {
+			_, err = s.Announce(ctx)
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, ok := err.(*ErrChallengeRequired); ok {
+			return err
+		}
+
+		delay := s.getRefreshInterval(interval)
+		switch err {
+		case nil, ErrNewRPCURL:
+			backoff = interval
+		case ErrInvalidAuthToken, ErrSessionInvalidated:
+			// Perform a full login on the next heartbeat
+			s.clearTicket()
+			s.debugError("run: session invalidated", err)
+		default:
+			s.debugError("run: heartbeat failed", err)
+			delay = s.getRefreshInterval(backoff)
+			backoff *= 2
+			if backoff > maxRunBackoff {
+				backoff = maxRunBackoff
+			}
+		}
+
+		err = wait(ctx, delay)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+type testProvider struct{}
+
+func (p *testProvider) Login(ctx context.Context) (string, error) {
+	return "token", nil
+}
+
+func (p *testProvider) GetProviderString() string {
+	return "ptc"
+}
+
+func (p *testProvider) GetAccessToken() string {
+	return "token"
+}
+
+// rpcHandler answers request envelopes in place of the remote service
+type rpcHandler func(url string, request *protos.RequestEnvelope) *protos.ResponseEnvelope
+
+func (h rpcHandler) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	request := &protos.RequestEnvelope{}
+	err = proto.Unmarshal(body, request)
+	if err != nil {
+		return nil, err
+	}
+	out, err := proto.Marshal(h(req.URL.String(), request))
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(bytes.NewReader(out)),
+		Request:    req,
+	}, nil
+}
+
+func TestRunLogsInAgain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := NewSession(&testProvider{}, &Location{Lat: 1, Lon: 2}, &recordFeed{}, nil, false)
+	s.setURL("old.example.com/plfe")
+	s.setTicket(&protos.AuthTicket{
+		Start:             []byte("old"),
+		ExpireTimestampMs: uint64(time.Now().Add(-time.Hour).UnixNano() / int64(time.Millisecond)),
+	})
+
+	var logins, announces int
+	s.rpc.http.Transport = rpcHandler(func(url string, request *protos.RequestEnvelope) *protos.ResponseEnvelope {
+		if request.AuthInfo != nil {
+			logins++
+			if url != defaultURL {
+				t.Errorf("Expected the login at the default URL, got %s", url)
+			}
+			return &protos.ResponseEnvelope{
+				StatusCode: protos.ResponseEnvelope_OK_RPC_URL_IN_RESPONSE,
+				ApiUrl:     "new.example.com/plfe",
+				AuthTicket: &protos.AuthTicket{
+					Start:             []byte("new"),
+					ExpireTimestampMs: uint64(time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond)),
+				},
+			}
+		}
+
+		announces++
+		if string(request.AuthTicket.GetStart()) != "new" {
+			t.Errorf("Expected the new auth ticket, got %q", request.AuthTicket.GetStart())
+		}
+		if url != "https://new.example.com/plfe/rpc" {
+			t.Errorf("Expected the new RPC URL, got %s", url)
+		}
+		cancel()
+		return &protos.ResponseEnvelope{
+			StatusCode: protos.ResponseEnvelope_OK,
+			Returns:    make([][]byte, 7),
+		}
+	})
+
+	err := s.Run(ctx, time.Second)
+	if err != context.Canceled {
+		t.Errorf("Expected the run to be canceled, got %v", err)
+	}
+	if logins != 1 || announces != 1 {
+		t.Errorf("Expected one login and one announce, got %d and %d", logins, announces)
+	}
+}
+
+func TestRunInvalidAuthToken(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := NewSession(&testProvider{}, &Location{Lat: 1, Lon: 2}, &recordFeed{}, nil, false)
+
+	var logins, announces int
+	s.rpc.http.Transport = rpcHandler(func(url string, request *protos.RequestEnvelope) *protos.ResponseEnvelope {
+		if request.AuthInfo != nil {
+			logins++
+			return &protos.ResponseEnvelope{
+				StatusCode: protos.ResponseEnvelope_OK_RPC_URL_IN_RESPONSE,
+				ApiUrl:     "new.example.com/plfe",
+				AuthTicket: &protos.AuthTicket{Start: []byte("new")},
+			}
+		}
+
+		announces++
+		if announces == 1 {
+			return &protos.ResponseEnvelope{StatusCode: protos.ResponseEnvelope_INVALID_AUTH_TOKEN}
+		}
+		cancel()
+		return &protos.ResponseEnvelope{
+			StatusCode: protos.ResponseEnvelope_OK,
+			Returns:    make([][]byte, 7),
+		}
+	})
+
+	err := s.Run(ctx, time.Second)
+	if err != context.Canceled {
+		t.Errorf("Expected the run to be canceled, got %v", err)
+	}
+	if logins != 2 || announces != 2 {
+		t.Errorf("Expected a login after the rejected auth token, got %d logins and %d announces", logins, announces)
+	}
+}
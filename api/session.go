@@ -3,7 +3,6 @@ package api
 import (
 	"context"
 	"crypto/rand"
-	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -17,16 +16,23 @@ import (
 
 const defaultURL = "https://pgorelease.nianticlabs.com/plfe/rpc"
 const downloadSettingsHash = "05daf51635c82611d1aac95c0b051d3ec088a930"
+const clientVersion = "0.43.3"
+const appVersion = 4303
+const locale = "en-US"
 
 // Session is used to communicate with the Pokémon Go API
 type Session struct {
-	feed     Feed
+	feed     EntryFeed
 	location *Location
 	rpc      *RPC
 	url      string
 	debug    bool
 	debugger *jsonpb.Marshaler
 
+	scanRadius  float64
+	cellLevel   int
+	mapSettings *protos.MapSettings
+
 	hasTicket bool
 	ticket    *protos.AuthTicket
 	started   time.Time
@@ -36,6 +42,19 @@ type Session struct {
 	deviceInfo *protos.Signature_DeviceInfo
 }
 
+// accountProvider is implemented by auth providers that can tell the account they log in to
+type accountProvider interface {
+	GetUsername() string
+}
+
+// envelope is a response envelope together with the context of the call it answers
+type envelope struct {
+	*protos.ResponseEnvelope
+	requests []*protos.Request
+	location Location
+	received time.Time
+}
+
 func generateRequests() []*protos.Request {
 	return make([]*protos.Request, 0)
 }
@@ -65,10 +84,11 @@ func NewSession(provider auth.Provider, location *Location, feed Feed, deviceInf
 		provider:  provider,
 		debug:     debug,
 		debugger:  &jsonpb.Marshaler{Indent: "\t"},
-		feed:      feed,
+		feed:      toEntryFeed(feed),
 		started:   time.Now(),
 		hasTicket: false,
 		hash:      make([]byte, 32),
+		cellLevel: cellIDLevel,
 		deviceInfo:deviceInfo,
 	}
 }
@@ -78,6 +98,24 @@ func (s *Session) SetTimeout(d time.Duration) {
 	s.rpc.http.Timeout = d
 }
 
+// SetScanArea sets the radius in meters and the cell level of the map cells requested when announcing
+func (s *Session) SetScanArea(radius float64, level int) error {
+	err := ValidateScanArea(radius, level)
+	if err != nil {
+		return err
+	}
+	s.scanRadius = radius
+	s.cellLevel = level
+	return nil
+}
+
+func (s *Session) getCellIDs() (CellIDs, error) {
+	if s.scanRadius > 0 {
+		return s.location.GetCellIDsInRadius(s.scanRadius, s.cellLevel)
+	}
+	return s.location.GetCellIDs(), nil
+}
+
 func (s *Session) setTicket(ticket *protos.AuthTicket) {
 	s.hasTicket = true
 	s.ticket = ticket
@@ -194,6 +232,91 @@ func (s *Session) Call(ctx context.Context, requests []*protos.Request) (*protos
 	return responseEnvelope, err
 }
 
+func (s *Session) call(ctx context.Context, requests []*protos.Request) (*envelope, error) {
+	location := *s.location
+	response, err := s.Call(ctx, requests)
+	if err != nil {
+		return nil, err
+	}
+	return &envelope{
+		ResponseEnvelope: response,
+		requests:         requests,
+		location:         location,
+		received:         time.Now(),
+	}, nil
+}
+
+func (s *Session) callRequest(ctx context.Context, requestType protos.RequestType, message proto.Message) (*envelope, error) {
+	request := &protos.Request{RequestType: requestType}
+	if message != nil {
+		requestMessage, err := proto.Marshal(message)
+		if err != nil {
+			return nil, ErrFormatting
+		}
+		request.RequestMessage = requestMessage
+	}
+	return s.call(ctx, []*protos.Request{request})
+}
+
+// push puts the message on to the feed together with the context of the response return at the index
+func (s *Session) push(response *envelope, index int, message interface{}) {
+	entry := &FeedEntry{
+		Message:         message,
+		Provider:        s.provider.GetProviderString(),
+		Location:        response.location,
+		RequestID:       response.RequestId,
+		StatusCode:      response.StatusCode,
+		Timestamp:       response.received,
+		ServerTimestamp: getServerTimestamp(message),
+	}
+	if account, ok := s.provider.(accountProvider); ok {
+		entry.Username = account.GetUsername()
+	}
+	if index < len(response.requests) {
+		entry.RequestType = response.requests[index].RequestType
+	}
+	if ticket := response.GetAuthTicket(); ticket.GetExpireTimestampMs() > 0 {
+		entry.TicketExpiry = fromTimestampMs(int64(ticket.ExpireTimestampMs))
+	}
+	s.feed.PushEntry(entry)
+}
+
+func (s *Session) decodeReturn(response *envelope, index int, message proto.Message) error {
+	if len(response.Returns) <= index {
+		return ErrEmptyResponse
+	}
+	err := proto.Unmarshal(response.Returns[index], message)
+	if err != nil {
+		return &ErrResponse{err}
+	}
+	s.push(response, index, message)
+	s.debugProtoMessage(fmt.Sprintf("response return[%d]", index), message)
+	return nil
+}
+
+func (s *Session) decodeSettings(response *envelope, index int) error {
+	settings := &protos.DownloadSettingsResponse{}
+	err := s.decodeReturn(response, index, settings)
+	if err != nil {
+		return err
+	}
+	// Settings are only sent when they have changed
+	if mapSettings := settings.GetSettings().GetMapSettings(); mapSettings != nil {
+		s.mapSettings = mapSettings
+	}
+	return nil
+}
+
+// SetFeed sets the feed where all responses are pushed
+func (s *Session) SetFeed(feed Feed) {
+	s.feed = toEntryFeed(feed)
+}
+
+// SetEntryFeed sets the feed where all responses are pushed together with their context
+func (s *Session) SetEntryFeed(feed EntryFeed) {
+	s.feed = feed
+}
+
 // MoveTo sets your current location
 func (s *Session) MoveTo(location *Location) {
 	s.location = location
@@ -223,7 +346,7 @@ func (s *Session) Init(ctx context.Context) error {
 		{protos.RequestType_DOWNLOAD_SETTINGS, settingsMessage},
 	}
 
-	response, err := s.Call(ctx, requests)
+	response, err := s.call(ctx, requests)
 	if err != nil {
 		return err
 	}
@@ -237,13 +360,28 @@ func (s *Session) Init(ctx context.Context) error {
 	ticket := response.GetAuthTicket()
 	s.setTicket(ticket)
 
+	// The returns are left out when the response only redirects to the API URL
+	if len(response.Returns) > 4 {
+		err = s.decodeReturn(response, 3, &protos.CheckAwardedBadgesResponse{})
+		if err != nil {
+			return err
+		}
+		err = s.decodeSettings(response, 4)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 // Announce publishes the player's presence and returns the map environment
 func (s *Session) Announce(ctx context.Context) (mapObjects *protos.GetMapObjectsResponse, err error) {
 
-	cellIDs := s.location.GetCellIDs()
+	cellIDs, err := s.getCellIDs()
+	if err != nil {
+		return nil, err
+	}
 	lastTimestamp := time.Now().Unix() * 1000
 
 	settingsMessage, _ := proto.Marshal(&protos.DownloadSettingsMessage{
@@ -275,40 +413,57 @@ func (s *Session) Announce(ctx context.Context) (mapObjects *protos.GetMapObject
 		{RequestType: protos.RequestType_CHECK_CHALLENGE},
 	}
 
-	response, err := s.Call(ctx, requests)
+	response, err := s.call(ctx, requests)
 	if err != nil {
 		return mapObjects, ErrRequest
 	}
 
+	// Rejected sessions and tickets are answered without returns
+	status := GetErrorFromStatus(response.StatusCode)
+	if status != nil && status != ErrNewRPCURL {
+		return nil, status
+	}
+
 	mapObjects = &protos.GetMapObjectsResponse{}
 	if len(response.Returns) < 5 {
-		return nil, errors.New("Empty response")
+		return nil, ErrEmptyResponse
+	}
+	err = s.decodeReturn(response, 3, &protos.CheckAwardedBadgesResponse{})
+	if err != nil {
+		return nil, err
 	}
-	err = proto.Unmarshal(response.Returns[5], mapObjects)
+	err = s.decodeSettings(response, 4)
 	if err != nil {
-		return nil, &ErrResponse{err}
+		return nil, err
+	}
+	err = s.decodeReturn(response, 5, mapObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	// A missing challenge check means that there is no challenge to solve
+	if len(response.Returns) > 6 {
+		_, err = s.checkChallenge(response, 6)
+		if err != nil {
+			return mapObjects, err
+		}
 	}
-	s.feed.Push(mapObjects)
-	s.debugProtoMessage("response return[5]", mapObjects)
 
-	return mapObjects, GetErrorFromStatus(response.StatusCode)
+	return mapObjects, status
 }
 
 // GetPlayer returns the current player profile
 func (s *Session) GetPlayer(ctx context.Context) (*protos.GetPlayerResponse, error) {
-	requests := []*protos.Request{{RequestType: protos.RequestType_GET_PLAYER}}
-	response, err := s.Call(ctx, requests)
+	response, err := s.callRequest(ctx, protos.RequestType_GET_PLAYER, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	player := &protos.GetPlayerResponse{}
-	err = proto.Unmarshal(response.Returns[0], player)
+	err = s.decodeReturn(response, 0, player)
 	if err != nil {
-		return nil, &ErrResponse{err}
+		return nil, err
 	}
-	s.feed.Push(player)
-	s.debugProtoMessage("response return[0]", player)
 
 	return player, GetErrorFromStatus(response.StatusCode)
 }
@@ -320,18 +475,15 @@ func (s *Session) GetPlayerMap(ctx context.Context) (*protos.GetMapObjectsRespon
 
 // GetInventory returns the player items
 func (s *Session) GetInventory(ctx context.Context) (*protos.GetInventoryResponse, error) {
-	requests := []*protos.Request{{RequestType: protos.RequestType_GET_INVENTORY}}
-	response, err := s.Call(ctx, requests)
+	response, err := s.callRequest(ctx, protos.RequestType_GET_INVENTORY, nil)
 	if err != nil {
 		return nil, err
 	}
 	inventory := &protos.GetInventoryResponse{}
-	err = proto.Unmarshal(response.Returns[0], inventory)
+	err = s.decodeReturn(response, 0, inventory)
 	if err != nil {
-		return nil, &ErrResponse{err}
+		return nil, err
 	}
-	s.feed.Push(inventory)
-	s.debugProtoMessage("response return[0]", inventory)
 
 	return inventory, GetErrorFromStatus(response.StatusCode)
 }
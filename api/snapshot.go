@@ -0,0 +1,248 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+// Wild Pokémon with a time till hidden outside of this range do not have a known despawn time
+const maxTimeTillHiddenMs = 3600000
+
+// SnapshotWildPokemon is a wild Pokémon on the map
+type SnapshotWildPokemon struct {
+	Pokemon     *protos.WildPokemon
+	CellID      uint64
+	DespawnTime time.Time
+	Distance    float64
+}
+
+// SnapshotCatchablePokemon is a Pokémon on the map within reach of the player
+type SnapshotCatchablePokemon struct {
+	Pokemon     *protos.MapPokemon
+	CellID      uint64
+	DespawnTime time.Time
+	Distance    float64
+}
+
+// SnapshotNearbyPokemon is a Pokémon close to the player without a known location
+type SnapshotNearbyPokemon struct {
+	Pokemon  *protos.NearbyPokemon
+	CellID   uint64
+	Distance float64
+}
+
+// SnapshotFort is a gym or pokéstop on the map
+type SnapshotFort struct {
+	Fort     *protos.FortData
+	CellID   uint64
+	Distance float64
+}
+
+// SnapshotSpawnPoint is a location where Pokémon appear
+type SnapshotSpawnPoint struct {
+	SpawnPoint *protos.SpawnPoint
+	CellID     uint64
+	Decimated  bool
+	Distance   float64
+}
+
+// MapSnapshot contains the objects of all map cells in a map objects response, without duplicates
+type MapSnapshot struct {
+	Location          Location
+	Timestamp         time.Time
+	WildPokemons      []*SnapshotWildPokemon
+	CatchablePokemons []*SnapshotCatchablePokemon
+	NearbyPokemons    []*SnapshotNearbyPokemon
+	Forts             []*SnapshotFort
+	SpawnPoints       []*SnapshotSpawnPoint
+}
+
+func fromTimestampMs(ms int64) time.Time {
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
+
+// getDespawnTime returns when the wild Pokémon seen at the time disappears, or the zero time if it is unknown
+func getDespawnTime(pokemon *protos.WildPokemon, timestamp time.Time) time.Time {
+	if pokemon.TimeTillHiddenMs <= 0 || pokemon.TimeTillHiddenMs > maxTimeTillHiddenMs {
+		return time.Time{}
+	}
+	seen := timestamp
+	if pokemon.LastModifiedTimestampMs > 0 {
+		seen = fromTimestampMs(pokemon.LastModifiedTimestampMs)
+	}
+	return seen.Add(time.Duration(pokemon.TimeTillHiddenMs) * time.Millisecond)
+}
+
+// NewMapSnapshot flattens the map cells of a map objects response scanned from the location at the time
+func NewMapSnapshot(mapObjects *protos.GetMapObjectsResponse, location *Location, timestamp time.Time) *MapSnapshot {
+	snapshot := &MapSnapshot{
+		Location:          *location,
+		Timestamp:         timestamp,
+		WildPokemons:      make([]*SnapshotWildPokemon, 0),
+		CatchablePokemons: make([]*SnapshotCatchablePokemon, 0),
+		NearbyPokemons:    make([]*SnapshotNearbyPokemon, 0),
+		Forts:             make([]*SnapshotFort, 0),
+		SpawnPoints:       make([]*SnapshotSpawnPoint, 0),
+	}
+
+	seenWild := make(map[uint64]bool)
+	seenCatchable := make(map[uint64]bool)
+	seenNearby := make(map[uint64]bool)
+	seenForts := make(map[string]bool)
+	seenSpawnPoints := make(map[string]bool)
+
+	for _, cell := range mapObjects.GetMapCells() {
+		for _, pokemon := range cell.WildPokemons {
+			if seenWild[pokemon.EncounterId] {
+				continue
+			}
+			seenWild[pokemon.EncounterId] = true
+			wild := &SnapshotWildPokemon{
+				Pokemon:  pokemon,
+				CellID:   cell.S2CellId,
+				Distance: location.DistanceToCoordinates(pokemon.Latitude, pokemon.Longitude),
+			}
+			wild.DespawnTime = getDespawnTime(pokemon, timestamp)
+			snapshot.WildPokemons = append(snapshot.WildPokemons, wild)
+		}
+
+		for _, pokemon := range cell.CatchablePokemons {
+			if seenCatchable[pokemon.EncounterId] {
+				continue
+			}
+			seenCatchable[pokemon.EncounterId] = true
+			catchable := &SnapshotCatchablePokemon{
+				Pokemon:  pokemon,
+				CellID:   cell.S2CellId,
+				Distance: location.DistanceToCoordinates(pokemon.Latitude, pokemon.Longitude),
+			}
+			if pokemon.ExpirationTimestampMs > 0 {
+				catchable.DespawnTime = fromTimestampMs(pokemon.ExpirationTimestampMs)
+			}
+			snapshot.CatchablePokemons = append(snapshot.CatchablePokemons, catchable)
+		}
+
+		for _, pokemon := range cell.NearbyPokemons {
+			if seenNearby[pokemon.EncounterId] {
+				continue
+			}
+			seenNearby[pokemon.EncounterId] = true
+			snapshot.NearbyPokemons = append(snapshot.NearbyPokemons, &SnapshotNearbyPokemon{
+				Pokemon:  pokemon,
+				CellID:   cell.S2CellId,
+				Distance: float64(pokemon.DistanceInMeters),
+			})
+		}
+
+		for _, fort := range cell.Forts {
+			if seenForts[fort.Id] {
+				continue
+			}
+			seenForts[fort.Id] = true
+			snapshot.Forts = append(snapshot.Forts, &SnapshotFort{
+				Fort:     fort,
+				CellID:   cell.S2CellId,
+				Distance: location.DistanceToFort(fort),
+			})
+		}
+
+		addSpawnPoints := func(spawnPoints []*protos.SpawnPoint, decimated bool) {
+			for _, spawnPoint := range spawnPoints {
+				key := fmt.Sprintf("%f,%f", spawnPoint.Latitude, spawnPoint.Longitude)
+				if seenSpawnPoints[key] {
+					continue
+				}
+				seenSpawnPoints[key] = true
+				snapshot.SpawnPoints = append(snapshot.SpawnPoints, &SnapshotSpawnPoint{
+					SpawnPoint: spawnPoint,
+					CellID:     cell.S2CellId,
+					Decimated:  decimated,
+					Distance:   location.DistanceToCoordinates(spawnPoint.Latitude, spawnPoint.Longitude),
+				})
+			}
+		}
+		addSpawnPoints(cell.SpawnPoints, false)
+		addSpawnPoints(cell.DecimatedSpawnPoints, true)
+	}
+
+	return snapshot
+}
+
+// NearestFort returns the closest fort of any of the types, or of any type if none are given
+func (m *MapSnapshot) NearestFort(types ...protos.FortType) *SnapshotFort {
+	var nearest *SnapshotFort
+	distance := math.Inf(1)
+	for _, fort := range m.Forts {
+		if len(types) > 0 && !hasFortType(types, fort.Fort.Type) {
+			continue
+		}
+		if fort.Distance < distance {
+			nearest = fort
+			distance = fort.Distance
+		}
+	}
+	return nearest
+}
+
+func hasFortType(types []protos.FortType, fortType protos.FortType) bool {
+	for _, t := range types {
+		if t == fortType {
+			return true
+		}
+	}
+	return false
+}
+
+// FortsWithinRadius returns the forts within the radius in meters from the scan location
+func (m *MapSnapshot) FortsWithinRadius(radius float64) []*SnapshotFort {
+	forts := make([]*SnapshotFort, 0)
+	for _, fort := range m.Forts {
+		if fort.Distance <= radius {
+			forts = append(forts, fort)
+		}
+	}
+	return forts
+}
+
+// WildPokemonsWithinRadius returns the wild Pokémon within the radius in meters from the scan location
+func (m *MapSnapshot) WildPokemonsWithinRadius(radius float64) []*SnapshotWildPokemon {
+	pokemons := make([]*SnapshotWildPokemon, 0)
+	for _, pokemon := range m.WildPokemons {
+		if pokemon.Distance <= radius {
+			pokemons = append(pokemons, pokemon)
+		}
+	}
+	return pokemons
+}
+
+// WildPokemonsBySpecies returns the wild Pokémon of any of the species
+func (m *MapSnapshot) WildPokemonsBySpecies(species ...protos.PokemonId) []*SnapshotWildPokemon {
+	pokemons := make([]*SnapshotWildPokemon, 0)
+	for _, pokemon := range m.WildPokemons {
+		pokemonID := pokemon.Pokemon.GetPokemonData().GetPokemonId()
+		for _, s := range species {
+			if s == pokemonID {
+				pokemons = append(pokemons, pokemon)
+				break
+			}
+		}
+	}
+	return pokemons
+}
+
+// NearbyPokemonsBySpecies returns the nearby Pokémon of any of the species
+func (m *MapSnapshot) NearbyPokemonsBySpecies(species ...protos.PokemonId) []*SnapshotNearbyPokemon {
+	pokemons := make([]*SnapshotNearbyPokemon, 0)
+	for _, pokemon := range m.NearbyPokemons {
+		for _, s := range species {
+			if s == pokemon.Pokemon.PokemonId {
+				pokemons = append(pokemons, pokemon)
+				break
+			}
+		}
+	}
+	return pokemons
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+func TestNewMapSnapshot(t *testing.T) {
+	pidgey := &protos.WildPokemon{
+		EncounterId:             1,
+		Latitude:                0.001,
+		Longitude:               0,
+		LastModifiedTimestampMs: 1000000,
+		TimeTillHiddenMs:        60000,
+		PokemonData:             &protos.PokemonData{PokemonId: protos.PokemonId_PIDGEY},
+	}
+	gym := &protos.FortData{Id: "gym", Type: protos.FortType_GYM, Latitude: 0.002}
+	pokestop := &protos.FortData{Id: "pokestop", Type: protos.FortType_CHECKPOINT, Latitude: 0.0005}
+
+	mapObjects := &protos.GetMapObjectsResponse{
+		MapCells: []*protos.MapCell{
+			{S2CellId: 1, WildPokemons: []*protos.WildPokemon{pidgey}, Forts: []*protos.FortData{gym}},
+			{S2CellId: 2, WildPokemons: []*protos.WildPokemon{pidgey}, Forts: []*protos.FortData{gym, pokestop}},
+		},
+	}
+
+	snapshot := NewMapSnapshot(mapObjects, &Location{}, time.Now())
+
+	if len(snapshot.WildPokemons) != 1 || len(snapshot.Forts) != 2 {
+		t.Fatalf("Expected duplicates to be removed, got %d Pokémon and %d forts", len(snapshot.WildPokemons), len(snapshot.Forts))
+	}
+
+	wild := snapshot.WildPokemons[0]
+	if wild.CellID != 1 {
+		t.Errorf("Expected the Pokémon to be in the first cell it was seen in, got %d", wild.CellID)
+	}
+	if expected := time.Unix(1060, 0); !wild.DespawnTime.Equal(expected) {
+		t.Errorf("Expected despawn time %s, got %s", expected, wild.DespawnTime)
+	}
+
+	if nearest := snapshot.NearestFort(); nearest.Fort.Id != "pokestop" {
+		t.Errorf("Expected the pokéstop to be nearest, got %s", nearest.Fort.Id)
+	}
+	if nearest := snapshot.NearestFort(protos.FortType_GYM); nearest.Fort.Id != "gym" {
+		t.Errorf("Expected the gym to be the nearest gym, got %s", nearest.Fort.Id)
+	}
+	if forts := snapshot.FortsWithinRadius(100); len(forts) != 1 {
+		t.Errorf("Expected one fort within 100 meters, got %d", len(forts))
+	}
+	if pokemons := snapshot.WildPokemonsBySpecies(protos.PokemonId_PIKACHU); len(pokemons) != 0 {
+		t.Errorf("Expected no Pikachu, got %d", len(pokemons))
+	}
+	if pokemons := snapshot.WildPokemonsBySpecies(protos.PokemonId_PIDGEY); len(pokemons) != 1 {
+		t.Errorf("Expected one Pidgey, got %d", len(pokemons))
+	}
+}
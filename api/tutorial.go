@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+const defaultStarter = protos.PokemonId_BULBASAUR
+
+// TutorialOptions contains the choices made on behalf of the player when completing the tutorial
+type TutorialOptions struct {
+	// Avatar is the look of the player, the default avatar is used when it is nil
+	Avatar *protos.PlayerAvatar
+	// Starter is the first Pokémon to catch, defaults to Bulbasaur
+	Starter protos.PokemonId
+	// Codename is the trainer name to claim, required if the name selection has not been completed
+	Codename string
+}
+
+// MarkTutorialComplete marks the tutorial steps as completed
+func (s *Session) MarkTutorialComplete(ctx context.Context, tutorials []protos.TutorialState) (*protos.MarkTutorialCompleteResponse, error) {
+	message := &protos.MarkTutorialCompleteMessage{
+		TutorialsCompleted:    tutorials,
+		SendMarketingEmails:   false,
+		SendPushNotifications: false,
+	}
+	response, err := s.callRequest(ctx, protos.RequestType_MARK_TUTORIAL_COMPLETE, message)
+	if err != nil {
+		return nil, err
+	}
+
+	completed := &protos.MarkTutorialCompleteResponse{}
+	err = s.decodeReturn(response, 0, completed)
+	if err != nil {
+		return nil, err
+	}
+
+	return completed, GetErrorFromStatus(response.StatusCode)
+}
+
+// SetAvatar sets the look of the player
+func (s *Session) SetAvatar(ctx context.Context, avatar *protos.PlayerAvatar) (*protos.SetAvatarResponse, error) {
+	message := &protos.SetAvatarMessage{
+		PlayerAvatar: avatar,
+	}
+	response, err := s.callRequest(ctx, protos.RequestType_SET_AVATAR, message)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &protos.SetAvatarResponse{}
+	err = s.decodeReturn(response, 0, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, GetErrorFromStatus(response.StatusCode)
+}
+
+// EncounterTutorialComplete catches the starter Pokémon of the tutorial
+func (s *Session) EncounterTutorialComplete(ctx context.Context, pokemonID protos.PokemonId) (*protos.EncounterTutorialCompleteResponse, error) {
+	message := &protos.EncounterTutorialCompleteMessage{
+		PokemonId: pokemonID,
+	}
+	response, err := s.callRequest(ctx, protos.RequestType_ENCOUNTER_TUTORIAL_COMPLETE, message)
+	if err != nil {
+		return nil, err
+	}
+
+	encounter := &protos.EncounterTutorialCompleteResponse{}
+	err = s.decodeReturn(response, 0, encounter)
+	if err != nil {
+		return nil, err
+	}
+
+	return encounter, GetErrorFromStatus(response.StatusCode)
+}
+
+// CheckCodenameAvailable checks if a trainer name can be claimed by the player
+func (s *Session) CheckCodenameAvailable(ctx context.Context, codename string) (*protos.CheckCodenameAvailableResponse, error) {
+	message := &protos.CheckCodenameAvailableMessage{
+		Codename: codename,
+	}
+	response, err := s.callRequest(ctx, protos.RequestType_CHECK_CODENAME_AVAILABLE, message)
+	if err != nil {
+		return nil, err
+	}
+
+	available := &protos.CheckCodenameAvailableResponse{}
+	err = s.decodeReturn(response, 0, available)
+	if err != nil {
+		return nil, err
+	}
+
+	return available, GetErrorFromStatus(response.StatusCode)
+}
+
+// ClaimCodename claims a trainer name for the player
+func (s *Session) ClaimCodename(ctx context.Context, codename string) (*protos.ClaimCodenameResponse, error) {
+	message := &protos.ClaimCodenameMessage{
+		Codename: codename,
+	}
+	response, err := s.callRequest(ctx, protos.RequestType_CLAIM_CODENAME, message)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := &protos.ClaimCodenameResponse{}
+	err = s.decodeReturn(response, 0, claimed)
+	if err != nil {
+		return nil, err
+	}
+
+	return claimed, GetErrorFromStatus(response.StatusCode)
+}
+
+// CompleteTutorial looks up the tutorial state of the player and performs the steps that are missing
+func (s *Session) CompleteTutorial(ctx context.Context, options *TutorialOptions) error {
+	if options == nil {
+		options = &TutorialOptions{}
+	}
+
+	player, err := s.GetPlayer(ctx)
+	if isFailure(err) {
+		return err
+	}
+
+	completed := make(map[protos.TutorialState]bool)
+	for _, state := range player.GetPlayerData().GetTutorialState() {
+		completed[state] = true
+	}
+
+	if !completed[protos.TutorialState_LEGAL_SCREEN] {
+		_, err = s.MarkTutorialComplete(ctx, []protos.TutorialState{protos.TutorialState_LEGAL_SCREEN})
+		if isFailure(err) {
+			return err
+		}
+	}
+
+	if !completed[protos.TutorialState_AVATAR_SELECTION] {
+		avatar := options.Avatar
+		if avatar == nil {
+			avatar = &protos.PlayerAvatar{}
+		}
+		_, err = s.SetAvatar(ctx, avatar)
+		if isFailure(err) {
+			return err
+		}
+		_, err = s.MarkTutorialComplete(ctx, []protos.TutorialState{protos.TutorialState_AVATAR_SELECTION})
+		if isFailure(err) {
+			return err
+		}
+	}
+
+	if !completed[protos.TutorialState_POKEMON_CAPTURE] {
+		starter := options.Starter
+		if starter == protos.PokemonId_MISSINGNO {
+			starter = defaultStarter
+		}
+		_, err = s.EncounterTutorialComplete(ctx, starter)
+		if isFailure(err) {
+			return err
+		}
+	}
+
+	if !completed[protos.TutorialState_NAME_SELECTION] {
+		if options.Codename == "" {
+			return ErrCodenameUnavailable
+		}
+		available, err := s.CheckCodenameAvailable(ctx, options.Codename)
+		if isFailure(err) {
+			return err
+		}
+		if !available.IsAssignable {
+			return ErrCodenameUnavailable
+		}
+		_, err = s.ClaimCodename(ctx, options.Codename)
+		if isFailure(err) {
+			return err
+		}
+	}
+
+	if !completed[protos.TutorialState_FIRST_TIME_EXPERIENCE_COMPLETE] {
+		_, err = s.MarkTutorialComplete(ctx, []protos.TutorialState{protos.TutorialState_FIRST_TIME_EXPERIENCE_COMPLETE})
+		if isFailure(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -46,6 +46,11 @@ func (p *Provider) GetProviderString() string {
 	return providerString
 }
 
+// GetUsername will return the username of the account logged in to
+func (p *Provider) GetUsername() string {
+	return p.username
+}
+
 // GetAccessToken will return an access token if it has been retrieved
 func (p *Provider) GetAccessToken() string {
 	return p.ticket
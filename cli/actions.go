@@ -1,14 +1,18 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 
 	"github.com/urfave/cli"
 
 	"github.com/pogodevorg/pgoapi-go/api"
 	"github.com/pogodevorg/pgoapi-go/auth"
+	"github.com/pogodevorg/pgoapi-go/geo"
+	"github.com/pogodevorg/pgoapi-go/plan"
 )
 
 func fail(e error) *cli.ExitError {
@@ -72,7 +76,7 @@ func getInventory(ctx context.Context, session *api.Session, provider auth.Provi
 	return nil
 }
 
-func getMap(ctx context.Context, session *api.Session, provider auth.Provider) error {
+func (w *wrapper) getMap(ctx context.Context, session *api.Session, provider auth.Provider) error {
 	err := session.Init(ctx)
 	if isFailure(err) {
 		return fail(err)
@@ -81,7 +85,20 @@ func getMap(ctx context.Context, session *api.Session, provider auth.Provider) e
 	if isFailure(err) {
 		return fail(err)
 	}
-	out, err := json.Marshal(mapObjects)
+
+	var out []byte
+	switch w.format {
+	case "json":
+		out, err = json.Marshal(mapObjects)
+	case "geojson":
+		out, err = json.Marshal(geo.FromMapObjects(mapObjects))
+	case "gpx":
+		var buf bytes.Buffer
+		err = geo.GPXFromMapObjects(mapObjects).Encode(&buf)
+		out = buf.Bytes()
+	default:
+		return cli.NewExitError(fmt.Sprintf("Format \"%s\" is not supported", w.format), 1)
+	}
 	if isFailure(err) {
 		return fail(err)
 	}
@@ -89,3 +106,92 @@ func getMap(ctx context.Context, session *api.Session, provider auth.Provider) e
 	fmt.Println(string(out))
 	return nil
 }
+
+func (w *wrapper) getItemTemplates(ctx context.Context, session *api.Session, provider auth.Provider) error {
+	err := session.Init(ctx)
+	if isFailure(err) {
+		return fail(err)
+	}
+	templates, err := api.NewAssetCache(w.cacheDir).ItemTemplates(ctx, session)
+	if isFailure(err) {
+		return fail(err)
+	}
+	out, err := json.Marshal(templates)
+	if isFailure(err) {
+		return fail(err)
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
+
+func (w *wrapper) plan(c *cli.Context) error {
+	if !(w.scanRadius > 0) || (w.polygonFile == "" && !(w.areaRadius > 0)) {
+		return cli.NewExitError("The area and scan radius must be larger than zero", 1)
+	}
+
+	var area plan.Area
+	if w.polygonFile != "" {
+		data, err := ioutil.ReadFile(w.polygonFile)
+		if err != nil {
+			return fail(err)
+		}
+		rings, err := geo.ParsePolygon(data)
+		if err != nil {
+			return fail(err)
+		}
+		area = &plan.Polygon{Rings: rings}
+	} else {
+		area = &plan.Circle{
+			Center: api.Location{Lat: w.lat, Lon: w.lon, Alt: w.alt, Accuracy: w.accuracy},
+			Radius: w.areaRadius,
+		}
+	}
+
+	var locations []api.Location
+	var cellIDs api.CellIDs
+	switch w.planMethod {
+	case "hex":
+		var err error
+		locations, err = plan.Hexagonal(area, w.scanRadius)
+		if err != nil {
+			return fail(err)
+		}
+	case "s2":
+		err := api.ValidateCellLevel(w.cellLevel)
+		if err != nil {
+			return fail(err)
+		}
+		locations, cellIDs, err = plan.Cells(area, w.cellLevel)
+		if err != nil {
+			return fail(err)
+		}
+	default:
+		return cli.NewExitError(fmt.Sprintf("Method \"%s\" is not supported", w.planMethod), 1)
+	}
+
+	var out []byte
+	var err error
+	switch w.format {
+	case "json":
+		out, err = json.Marshal(locations)
+	case "geojson":
+		fc := geo.NewFeatureCollection()
+		for idx, l := range locations {
+			properties := map[string]interface{}{"radius": w.scanRadius}
+			if cellIDs != nil {
+				properties = map[string]interface{}{"cell_id": cellIDs[idx]}
+			}
+			fc.Add(geo.Point(l), properties)
+		}
+		out, err = json.Marshal(fc)
+	default:
+		return cli.NewExitError(fmt.Sprintf("Format \"%s\" is not supported", w.format), 1)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
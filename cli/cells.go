@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/geo/s2"
+	"github.com/urfave/cli"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+	"github.com/pogodevorg/pgoapi-go/geo"
+)
+
+type cellInfo struct {
+	ID       uint64         `json:"id"`
+	Token    string         `json:"token"`
+	Level    int            `json:"level"`
+	Center   api.Location   `json:"center"`
+	Vertices []api.Location `json:"vertices"`
+}
+
+func newCellInfo(cellID uint64) *cellInfo {
+	id := s2.CellID(cellID)
+	center := id.LatLng()
+	return &cellInfo{
+		ID:       cellID,
+		Token:    id.ToToken(),
+		Level:    id.Level(),
+		Center:   api.Location{Lat: center.Lat.Degrees(), Lon: center.Lng.Degrees()},
+		Vertices: geo.CellVertices(cellID),
+	}
+}
+
+func (w *wrapper) cells(c *cli.Context) error {
+	location := &api.Location{
+		Lon:      w.lon,
+		Lat:      w.lat,
+		Alt:      w.alt,
+		Accuracy: w.accuracy,
+	}
+	err := location.Validate()
+	if err != nil {
+		return fail(err)
+	}
+
+	var cellIDs api.CellIDs
+	if w.radius > 0 {
+		cellIDs, err = location.GetCellIDsInRadius(w.radius, w.cellLevel)
+		if err != nil {
+			return fail(err)
+		}
+	} else {
+		cellIDs = location.GetCellIDs()
+	}
+
+	switch w.format {
+	case "text":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tTOKEN\tLEVEL\tCENTER\tVERTICES")
+		for _, cellID := range cellIDs {
+			info := newCellInfo(cellID)
+			vertices := make([]string, len(info.Vertices))
+			for idx, v := range info.Vertices {
+				vertices[idx] = fmt.Sprintf("(%f,%f)", v.Lat, v.Lon)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%d\t(%f,%f)\t%s\n", info.ID, info.Token, info.Level, info.Center.Lat, info.Center.Lon, strings.Join(vertices, " "))
+		}
+		return tw.Flush()
+	case "json":
+		infos := make([]*cellInfo, len(cellIDs))
+		for idx, cellID := range cellIDs {
+			infos[idx] = newCellInfo(cellID)
+		}
+		out, err := json.Marshal(infos)
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Println(string(out))
+	case "geojson":
+		fc := geo.NewFeatureCollection()
+		for _, cellID := range cellIDs {
+			info := newCellInfo(cellID)
+			fc.Add(geo.Cell(cellID), map[string]interface{}{
+				"id":    fmt.Sprintf("%d", info.ID),
+				"token": info.Token,
+				"level": info.Level,
+			})
+		}
+		out, err := json.Marshal(fc)
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Println(string(out))
+	default:
+		return cli.NewExitError(fmt.Sprintf("Format \"%s\" is not supported", w.format), 1)
+	}
+
+	return nil
+}
@@ -1,6 +1,10 @@
 package cli
 
-import "github.com/urfave/cli"
+import (
+	"time"
+
+	"github.com/urfave/cli"
+)
 
 // Run interprets arguments and performs actions
 func Run(args []string) {
@@ -60,7 +64,78 @@ func Run(args []string) {
 			Value:       3.0,
 			EnvVar:      "PGOAPI_DEFAULT_ACCURACY",
 		},
+		cli.Float64Flag{
+			Name:        "radius",
+			Destination: &w.radius,
+			Value:       0.0,
+			Usage:       "Radius in meters of the map cells to request, the closest cells are requested when unset",
+			EnvVar:      "PGOAPI_SCAN_RADIUS",
+		},
+		cli.IntFlag{
+			Name:        "cell-level",
+			Destination: &w.cellLevel,
+			Value:       15,
+			Usage:       "S2 cell level of the map cells to request within the radius",
+			EnvVar:      "PGOAPI_CELL_LEVEL",
+		},
+	}
+
+	// outputFlags configure where the responses of watch and replay go
+	outputFlags := []cli.Flag{
+		cli.StringFlag{
+			Name:        "format",
+			Destination: &w.format,
+			Value:       "json",
+			Usage:       "Output format can be either \"json\" or \"geojson\"",
+		},
+		cli.StringFlag{
+			Name:        "record",
+			Destination: &w.recordFile,
+			Usage:       "Also write all responses to the file",
+		},
+		cli.StringFlag{
+			Name:        "record-format",
+			Destination: &w.recordFormat,
+			Value:       "jsonl",
+			Usage:       "Record format can be either \"jsonl\" or \"binary\", binary records are not rotated",
+		},
+		cli.Int64Flag{
+			Name:        "rotate-size",
+			Destination: &w.rotateSize,
+			Usage:       "Size in bytes the record file may grow to before it is rotated",
+		},
+		cli.DurationFlag{
+			Name:        "rotate-interval",
+			Destination: &w.rotateInterval,
+			Usage:       "Time the record file is written to before it is rotated",
+		},
+		cli.BoolFlag{
+			Name:        "compress",
+			Destination: &w.compress,
+			Usage:       "Gzip compress rotated record files",
+		},
+		cli.StringFlag{
+			Name:        "history",
+			Destination: &w.historyFile,
+			Usage:       "Also record sightings and fort states in the history database",
+		},
+		cli.StringFlag{
+			Name:        "listen",
+			Destination: &w.listen,
+			Usage:       "Also stream all responses over WebSocket at /ws and Server-Sent Events at /events on the address",
+		},
+		cli.StringFlag{
+			Name:        "allow-origins",
+			Destination: &w.origins,
+			Usage:       "Comma separated origins of the web pages allowed to connect to the listen address, * allows all",
+		},
+		cli.StringFlag{
+			Name:        "rules",
+			Destination: &w.rulesFile,
+			Usage:       "Also route the entries matching the rules in the file to their targets",
+		},
 	}
+
 	app.Commands = []cli.Command{
 		{
 			Name:   "access_token",
@@ -80,7 +155,167 @@ func Run(args []string) {
 		{
 			Name:   "map",
 			Usage:  "Retrieves map data for the player's current location",
-			Action: w.wrap(getMap),
+			Action: w.wrap(w.getMap),
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "format",
+					Destination: &w.format,
+					Value:       "json",
+					Usage:       "Output format can be either \"json\", \"geojson\" or \"gpx\"",
+				},
+			},
+		},
+		{
+			Name:   "templates",
+			Usage:  "Retrieves the game master item templates",
+			Action: w.wrap(w.getItemTemplates),
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "cache",
+					Destination: &w.cacheDir,
+					Value:       ".pgoapi-cache",
+					Usage:       "Directory where downloaded templates are kept until they change",
+					EnvVar:      "PGOAPI_CACHE_DIR",
+				},
+			},
+		},
+		{
+			Name:   "plan",
+			Usage:  "Plans the scan locations covering a circle around the location or a GeoJSON polygon",
+			Action: w.plan,
+			Flags: []cli.Flag{
+				cli.Float64Flag{
+					Name:        "area-radius",
+					Destination: &w.areaRadius,
+					Value:       1000.0,
+					Usage:       "Radius in meters of the circle to cover around the location",
+				},
+				cli.StringFlag{
+					Name:        "polygon",
+					Destination: &w.polygonFile,
+					Usage:       "GeoJSON file with a polygon to cover instead of a circle",
+				},
+				cli.Float64Flag{
+					Name:        "scan-radius",
+					Destination: &w.scanRadius,
+					Value:       70.0,
+					Usage:       "Radius in meters covered by each scan location",
+				},
+				cli.StringFlag{
+					Name:        "method",
+					Destination: &w.planMethod,
+					Value:       "hex",
+					Usage:       "Plan a hexagonal grid with \"hex\" or cell centers with \"s2\"",
+				},
+				cli.StringFlag{
+					Name:        "format",
+					Destination: &w.format,
+					Value:       "json",
+					Usage:       "Output format can be either \"json\" or \"geojson\"",
+				},
+			},
+		},
+		{
+			Name:   "watch",
+			Usage:  "Announces the player in intervals and prints map data for the location until interrupted",
+			Action: w.wrap(w.watch),
+			Flags: append([]cli.Flag{
+				cli.DurationFlag{
+					Name:        "interval",
+					Destination: &w.interval,
+					Value:       10 * time.Second,
+					Usage:       "Time between announcements, the remote service may require a longer interval",
+				},
+			}, outputFlags...),
+		},
+		{
+			Name:   "replay",
+			Usage:  "Replays a recording as if the responses were received again",
+			Action: w.replay,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:        "file",
+					Destination: &w.replayFile,
+					Usage:       "JSON-lines or binary recording to replay, gzip compressed recordings are decompressed",
+				},
+				cli.BoolFlag{
+					Name:        "binary",
+					Destination: &w.binary,
+					Usage:       "Read the recording as binary, which is assumed for files ending in .bin",
+				},
+				cli.Float64Flag{
+					Name:        "speed",
+					Destination: &w.speed,
+					Usage:       "Replay at the original timing multiplied by the speed, as fast as possible when unset",
+				},
+			}, outputFlags...),
+		},
+		{
+			Name:   "history",
+			Usage:  "Queries the sightings and fort states recorded by watch",
+			Action: w.history,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "db",
+					Destination: &w.historyFile,
+					Value:       "history.db",
+					Usage:       "History database file",
+				},
+				cli.DurationFlag{
+					Name:        "since",
+					Destination: &w.since,
+					Usage:       "Only list records seen within the duration",
+				},
+				cli.StringFlag{
+					Name:        "species",
+					Destination: &w.species,
+					Usage:       "Comma separated Pokémon names or numbers to list",
+				},
+				cli.StringFlag{
+					Name:        "bbox",
+					Destination: &w.bbox,
+					Usage:       "Only list records within the bounding box \"minLat,minLon,maxLat,maxLon\"",
+				},
+				cli.BoolFlag{
+					Name:        "forts",
+					Destination: &w.forts,
+					Usage:       "List fort states instead of sightings",
+				},
+				cli.IntFlag{
+					Name:        "limit",
+					Destination: &w.limit,
+					Usage:       "Maximum number of records to list",
+				},
+				cli.DurationFlag{
+					Name:        "retain",
+					Destination: &w.retain,
+					Usage:       "Delete records older than the duration before listing",
+				},
+				cli.StringFlag{
+					Name:        "compact",
+					Destination: &w.compactFile,
+					Usage:       "Write a compacted copy of the database to the file",
+				},
+				cli.StringFlag{
+					Name:        "format",
+					Destination: &w.format,
+					Value:       "text",
+					Usage:       "Output format can be either \"text\", \"json\" or \"geojson\"",
+				},
+			},
+		},
+		{
+			Name:   "cells",
+			Usage:  "Prints the map cells requested for the location",
+			Action: w.cells,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "format",
+					Destination: &w.format,
+					Value:       "text",
+					Usage:       "Output format can be either \"text\", \"json\" or \"geojson\"",
+				},
+			},
 		},
 	}
 
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+	"github.com/urfave/cli"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+	"github.com/pogodevorg/pgoapi-go/geo"
+	"github.com/pogodevorg/pgoapi-go/history"
+)
+
+// parseSpecies parses a comma separated list of Pokémon names or numbers
+func parseSpecies(list string) ([]protos.PokemonId, error) {
+	species := make([]protos.PokemonId, 0)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if id, err := strconv.Atoi(name); err == nil {
+			species = append(species, protos.PokemonId(id))
+			continue
+		}
+		id, ok := protos.PokemonId_value[name]
+		if !ok {
+			return nil, fmt.Errorf("Pokémon \"%s\" is not known", name)
+		}
+		species = append(species, protos.PokemonId(id))
+	}
+	return species, nil
+}
+
+func (w *wrapper) historyQuery() (history.Query, error) {
+	query := history.Query{Limit: w.limit}
+	if w.since > 0 {
+		query.From = time.Now().Add(-w.since)
+	}
+	if w.species != "" {
+		species, err := parseSpecies(w.species)
+		if err != nil {
+			return query, err
+		}
+		query.Species = species
+	}
+	if w.bbox != "" {
+		box, err := api.ParseBoundingBox(w.bbox)
+		if err != nil {
+			return query, err
+		}
+		query.Box = box
+	}
+	return query, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func printSightings(format string, sightings []*history.Sighting) error {
+	switch format {
+	case "text":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "SEEN\tENCOUNTER\tPOKEMON\tLOCATION\tDESPAWN")
+		for _, s := range sightings {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t(%f,%f)\t%s\n", formatTime(s.SeenAt), s.EncounterID, s.PokemonID, s.Lat, s.Lon, formatTime(s.DespawnTime))
+		}
+		return tw.Flush()
+	case "json":
+		out, err := json.Marshal(sightings)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	case "geojson":
+		fc := geo.NewFeatureCollection()
+		for _, s := range sightings {
+			fc.Add(geo.Point(api.Location{Lat: s.Lat, Lon: s.Lon}), map[string]interface{}{
+				"encounter_id": fmt.Sprintf("%d", s.EncounterID),
+				"pokemon_id":   s.PokemonID,
+				"seen_at":      s.SeenAt,
+				"despawn_time": s.DespawnTime,
+			})
+		}
+		out, err := json.Marshal(fc)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	default:
+		return cli.NewExitError(fmt.Sprintf("Format \"%s\" is not supported", format), 1)
+	}
+	return nil
+}
+
+func printForts(format string, forts []*history.FortState) error {
+	switch format {
+	case "text":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "SEEN\tFORT\tTYPE\tTEAM\tPOINTS\tGUARD\tLURE EXPIRES")
+		for _, f := range forts {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", formatTime(f.SeenAt), f.FortID, f.Type, f.Team, f.GymPoints, f.GuardPokemonID, formatTime(f.LureExpires))
+		}
+		return tw.Flush()
+	case "json":
+		out, err := json.Marshal(forts)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	case "geojson":
+		fc := geo.NewFeatureCollection()
+		for _, f := range forts {
+			fc.Add(geo.Point(api.Location{Lat: f.Lat, Lon: f.Lon}), map[string]interface{}{
+				"fort_id":    f.FortID,
+				"type":       f.Type,
+				"team":       f.Team,
+				"gym_points": f.GymPoints,
+				"seen_at":    f.SeenAt,
+			})
+		}
+		out, err := json.Marshal(fc)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	default:
+		return cli.NewExitError(fmt.Sprintf("Format \"%s\" is not supported", format), 1)
+	}
+	return nil
+}
+
+func (w *wrapper) history(c *cli.Context) error {
+	query, err := w.historyQuery()
+	if err != nil {
+		return fail(err)
+	}
+
+	// Querying alone does not need to write, but the store cannot be opened while watch is recording to it
+	open := history.OpenReadOnly
+	if w.retain > 0 || w.compactFile != "" {
+		open = history.Open
+	}
+	store, err := open(w.historyFile)
+	if err != nil {
+		return fail(err)
+	}
+	defer store.Close()
+
+	if w.retain > 0 {
+		_, err = store.Prune(time.Now().Add(-w.retain))
+		if err != nil {
+			return fail(err)
+		}
+	}
+	if w.compactFile != "" {
+		err = store.Compact(w.compactFile)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	if w.forts {
+		forts, err := store.Forts(query)
+		if err != nil {
+			return fail(err)
+		}
+		return printForts(w.format, forts)
+	}
+	sightings, err := store.Sightings(query)
+	if err != nil {
+		return fail(err)
+	}
+	return printSightings(w.format, sightings)
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/pogodevorg/pgoapi-go/feed"
+)
+
+func (w *wrapper) replay(c *cli.Context) error {
+	file, err := os.Open(w.replayFile)
+	if err != nil {
+		return fail(err)
+	}
+	defer file.Close()
+
+	var reader feed.EntryReader
+	if w.binary || strings.HasSuffix(strings.TrimSuffix(w.replayFile, ".gz"), ".bin") {
+		reader, err = feed.NewBinaryReader(file)
+	} else {
+		reader, err = feed.NewJSONLinesReader(file)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	output, closeOutput, err := w.outputFeed()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	ctx, stop := interruptible(context.Background())
+	defer stop()
+
+	_, err = feed.Replay(ctx, reader, output, w.speed)
+	if err == context.Canceled {
+		return nil
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return nil
+}
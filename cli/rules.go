@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+	"github.com/pogodevorg/pgoapi-go/feed"
+	"github.com/pogodevorg/pgoapi-go/rules"
+)
+
+// recordPrinter prints every entry as a JSON record line to standard out
+type recordPrinter struct{}
+
+func (p *recordPrinter) PushEntry(entry *api.FeedEntry) {
+	record, err := feed.NewRecord(entry)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	out, err := json.Marshal(record)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	fmt.Println(string(out))
+}
+
+func (p *recordPrinter) Push(entry interface{}) {
+	p.PushEntry(&api.FeedEntry{Message: entry})
+}
+
+// routeFeed returns the feed routing entries and the events of what has changed on the map to the targets of
+// the rules file, the returned functions close the targets
+func (w *wrapper) routeFeed() (api.Feed, []func() error, error) {
+	closers := make([]func() error, 0)
+	targets := make(map[string]api.Feed)
+
+	// Rules with the same target share its feed
+	resolve := func(target string) (api.Feed, error) {
+		if f, ok := targets[target]; ok {
+			return f, nil
+		}
+
+		var f api.Feed
+		switch {
+		case target == "stdout":
+			f = &recordPrinter{}
+		case strings.HasPrefix(target, "jsonl:"):
+			record, err := feed.NewJSONLines(strings.TrimPrefix(target, "jsonl:"), feed.JSONLinesOptions{
+				MaxSize:  w.rotateSize,
+				MaxAge:   w.rotateInterval,
+				Compress: w.compress,
+			})
+			if err != nil {
+				return nil, err
+			}
+			closers = append(closers, record.Close)
+			f = record
+		case strings.HasPrefix(target, "webhook:"):
+			webhook := feed.NewWebhook([]feed.Endpoint{{URL: strings.TrimPrefix(target, "webhook:")}}, feed.WebhookOptions{
+				QueueSize:  100,
+				MaxRetries: 3,
+				Backoff:    time.Second,
+			})
+			closers = append(closers, webhook.Close)
+			f = webhook
+		default:
+			return nil, fmt.Errorf("target \"%s\" is not supported, use \"stdout\", \"jsonl:<file>\" or \"webhook:<url>\"", target)
+		}
+		targets[target] = f
+		return f, nil
+	}
+
+	loaded, err := rules.LoadRules(w.rulesFile, resolve)
+	if err != nil {
+		for _, c := range closers {
+			c()
+		}
+		return nil, nil, err
+	}
+	return api.NewDiffFeed(rules.NewRouter(loaded)), closers, nil
+}
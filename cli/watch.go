@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+	"github.com/urfave/cli"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+	"github.com/pogodevorg/pgoapi-go/auth"
+	"github.com/pogodevorg/pgoapi-go/feed"
+	"github.com/pogodevorg/pgoapi-go/geo"
+	"github.com/pogodevorg/pgoapi-go/history"
+)
+
+// printFeed prints every map objects response as a line to standard out
+type printFeed struct {
+	format string
+}
+
+func (f *printFeed) Push(entry interface{}) {
+	mapObjects, ok := entry.(*protos.GetMapObjectsResponse)
+	if !ok {
+		return
+	}
+
+	var out []byte
+	var err error
+	switch f.format {
+	case "geojson":
+		out, err = json.Marshal(geo.FromMapObjects(mapObjects))
+	default:
+		out, err = json.Marshal(mapObjects)
+	}
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	fmt.Println(string(out))
+}
+
+// teeFeed pushes every entry to all of its feeds, with its context to those receiving it
+type teeFeed []api.Feed
+
+func (t teeFeed) Push(entry interface{}) {
+	for _, f := range t {
+		f.Push(entry)
+	}
+}
+
+func (t teeFeed) PushEntry(entry *api.FeedEntry) {
+	for _, f := range t {
+		if entryFeed, ok := f.(api.EntryFeed); ok {
+			entryFeed.PushEntry(entry)
+		} else {
+			f.Push(entry.Message)
+		}
+	}
+}
+
+// recorder is a feed writing the responses to the record file
+type recorder interface {
+	api.Feed
+	Close() error
+}
+
+// recordFeed returns the feed writing to the record file in the record format
+func (w *wrapper) recordFeed() (recorder, error) {
+	switch w.recordFormat {
+	case "jsonl":
+		record, err := feed.NewJSONLines(w.recordFile, feed.JSONLinesOptions{
+			MaxSize:  w.rotateSize,
+			MaxAge:   w.rotateInterval,
+			Compress: w.compress,
+		})
+		if err != nil {
+			return nil, fail(err)
+		}
+		return record, nil
+	case "binary":
+		if w.rotateSize != 0 || w.rotateInterval != 0 || w.compress {
+			return nil, cli.NewExitError("Binary records can not be rotated or compressed", 1)
+		}
+		file, err := os.OpenFile(w.recordFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fail(err)
+		}
+		return feed.NewBinary(file), nil
+	default:
+		return nil, cli.NewExitError(fmt.Sprintf("Record format \"%s\" is not supported", w.recordFormat), 1)
+	}
+}
+
+// outputFeed returns the feed printing map objects and passing all entries on to the recorders enabled by
+// the flags, the returned function closes the recorders
+func (w *wrapper) outputFeed() (api.Feed, func(), error) {
+	if w.format != "json" && w.format != "geojson" {
+		return nil, nil, cli.NewExitError(fmt.Sprintf("Format \"%s\" is not supported", w.format), 1)
+	}
+
+	var output api.Feed = &printFeed{format: w.format}
+	closers := make([]func() error, 0)
+	closeAll := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+
+	if w.recordFile != "" {
+		record, err := w.recordFeed()
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		closers = append(closers, record.Close)
+		output = teeFeed{output, record}
+	}
+	if w.historyFile != "" {
+		store, err := history.Open(w.historyFile)
+		if err != nil {
+			closeAll()
+			return nil, nil, fail(err)
+		}
+		closers = append(closers, store.Close)
+		output = teeFeed{output, store}
+	}
+	if w.listen != "" {
+		server := feed.NewServer(100)
+		if w.origins != "" {
+			server.SetAllowedOrigins(strings.Split(w.origins, ","))
+		}
+		// The address is listened on before watching so a taken address fails the command
+		err := server.Listen(w.listen)
+		if err != nil {
+			closeAll()
+			return nil, nil, fail(err)
+		}
+		go func() {
+			err := server.Serve()
+			if err != nil {
+				log.Println(err)
+			}
+		}()
+		closers = append(closers, server.Close)
+		output = teeFeed{output, server}
+	}
+
+	if w.rulesFile != "" {
+		router, routeClosers, err := w.routeFeed()
+		if err != nil {
+			closeAll()
+			return nil, nil, fail(err)
+		}
+		closers = append(closers, routeClosers...)
+		output = teeFeed{output, router}
+	}
+
+	if len(closers) > 0 {
+		// Recorders are written to in the background and drained before they are closed
+		async := api.NewAsyncFeed(output, 1, 1000, api.OverflowBlock)
+		closers = append([]func() error{func() error {
+			async.Close()
+			return nil
+		}}, closers...)
+		output = async
+	}
+
+	return output, closeAll, nil
+}
+
+// interruptible returns a context that is cancelled when the process is interrupted
+func interruptible(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(interrupt)
+		cancel()
+	}
+}
+
+func (w *wrapper) watch(ctx context.Context, session *api.Session, provider auth.Provider) error {
+	output, closeOutput, err := w.outputFeed()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+	session.SetFeed(output)
+
+	ctx, stop := interruptible(ctx)
+	defer stop()
+
+	err = session.Run(ctx, w.interval)
+	if err == context.Canceled {
+		return nil
+	}
+	return fail(err)
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"context"
+	"time"
 
 	"github.com/urfave/cli"
 
@@ -19,7 +20,42 @@ type wrapper struct {
 	alt      float64
 	accuracy float64
 
+	radius    float64
+	cellLevel int
+
 	debug bool
+
+	cacheDir string
+
+	format      string
+	areaRadius  float64
+	polygonFile string
+	scanRadius  float64
+	planMethod  string
+
+	interval time.Duration
+
+	recordFile     string
+	recordFormat   string
+	rotateSize     int64
+	rotateInterval time.Duration
+	compress       bool
+
+	listen      string
+	origins     string
+	rulesFile   string
+	historyFile string
+	compactFile string
+	since       time.Duration
+	retain      time.Duration
+	species     string
+	bbox        string
+	forts       bool
+	limit       int
+
+	replayFile string
+	binary     bool
+	speed      float64
 }
 
 func (w *wrapper) wrap(action func(context.Context, *api.Session, auth.Provider) error) func(*cli.Context) error {
@@ -40,6 +76,12 @@ func (w *wrapper) wrap(action func(context.Context, *api.Session, auth.Provider)
 		}
 
 		client := api.NewSession(provider, location, &api.VoidFeed{}, nil, w.debug)
+		if w.radius > 0 {
+			err = client.SetScanArea(w.radius, w.cellLevel)
+			if err != nil {
+				return fail(err)
+			}
+		}
 
 		return action(ctx, client, provider)
 	}
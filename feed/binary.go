@@ -0,0 +1,180 @@
+package feed
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+// MaxFrameSize is the largest frame a binary reader accepts
+const MaxFrameSize = 64 << 20
+
+// Binary is a feed writing every entry as a frame of a JSON record header without the message, followed by
+// the protobuf encoded message. Messages that are not protobuf messages are JSON encoded. The header and
+// the message are both prefixed by their length as an unsigned varint. Frames are buffered until the feed is
+// flushed or closed.
+type Binary struct {
+	w      *bufio.Writer
+	closer io.Closer
+	now    func() time.Time
+
+	mutex sync.Mutex
+	err   error
+}
+
+// NewBinary constructs a binary feed writing to the writer, the writer is closed with the feed if it is an io.Closer
+func NewBinary(w io.Writer) *Binary {
+	closer, _ := w.(io.Closer)
+	return &Binary{
+		w:      bufio.NewWriter(w),
+		closer: closer,
+		now:    time.Now,
+	}
+}
+
+func writeFrame(w io.Writer, data []byte) error {
+	prefix := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(prefix, uint64(len(data)))
+	_, err := w.Write(prefix[:n])
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (b *Binary) write(entry *api.FeedEntry) error {
+	header := newHeader(entry)
+	if header.Timestamp.IsZero() {
+		header.Timestamp = b.now()
+	}
+	headerData, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	var message []byte
+	if pb, ok := entry.Message.(proto.Message); ok {
+		message, err = proto.Marshal(pb)
+	} else {
+		message, err = json.Marshal(entry.Message)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = writeFrame(b.w, headerData)
+	if err != nil {
+		return err
+	}
+	return writeFrame(b.w, message)
+}
+
+// PushEntry writes the entry as a frame
+func (b *Binary) PushEntry(entry *api.FeedEntry) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	err := b.write(entry)
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+}
+
+// Push writes the entry as a frame with the current time
+func (b *Binary) Push(entry interface{}) {
+	b.PushEntry(&api.FeedEntry{Message: entry})
+}
+
+// Flush writes the buffered frames to the writer
+func (b *Binary) Flush() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	err := b.w.Flush()
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	return err
+}
+
+// Close flushes the buffered frames and closes the writer, it returns the first error encountered while
+// writing entries
+func (b *Binary) Close() error {
+	err := b.Flush()
+	if b.closer != nil {
+		if closeErr := b.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return b.Err()
+}
+
+// Err returns the first error encountered while writing entries
+func (b *Binary) Err() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.err
+}
+
+// BinaryReader reads the entries of a binary recording
+type BinaryReader struct {
+	r *bufio.Reader
+}
+
+// NewBinaryReader constructs a reader of the binary recording, gzip compressed recordings are decompressed
+func NewBinaryReader(r io.Reader) (*BinaryReader, error) {
+	reader, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryReader{r: reader}, nil
+}
+
+func (b *BinaryReader) readFrame() ([]byte, error) {
+	size, err := binary.ReadUvarint(b.r)
+	if err != nil {
+		return nil, err
+	}
+	if size > MaxFrameSize {
+		return nil, ErrFrameTooLarge
+	}
+	data := make([]byte, size)
+	_, err = io.ReadFull(b.r, data)
+	return data, err
+}
+
+// Read returns the next entry, the error is io.EOF at the end of the recording and ErrUnknownType for
+// messages of unknown types, which can be skipped
+func (b *BinaryReader) Read() (*api.FeedEntry, error) {
+	headerData, err := b.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	message, err := b.readFrame()
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	header := &Record{}
+	err = json.Unmarshal(headerData, header)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := decodeMessage(header.Type, message, proto.Unmarshal)
+	if err != nil {
+		return nil, err
+	}
+	return header.entry(decoded), nil
+}
@@ -0,0 +1,12 @@
+package feed
+
+import "errors"
+
+// ErrUnknownType is returned when a record holds a message of a type that is not known
+var ErrUnknownType = errors.New("The record holds a message of an unknown type")
+
+// ErrClosed is returned when the feed has been closed
+var ErrClosed = errors.New("The feed has been closed")
+
+// ErrFrameTooLarge is returned when a frame of a binary recording is larger than the frame size limit
+var ErrFrameTooLarge = errors.New("The frame is larger than the frame size limit")
@@ -0,0 +1,257 @@
+package feed
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+const rotatedTimeFormat = "20060102T150405.000"
+
+// JSONLinesOptions configures when the file of a JSON-lines feed is rotated
+type JSONLinesOptions struct {
+	// MaxSize is the size in bytes a file may grow to before it is rotated, zero disables rotation by size
+	MaxSize int64
+	// MaxAge is how long a file is written to before it is rotated, zero disables rotation by time
+	MaxAge time.Duration
+	// Compress gzip compresses the rotated files
+	Compress bool
+}
+
+// JSONLines is a feed writing every entry as a record on a line of a file
+type JSONLines struct {
+	path    string
+	options JSONLinesOptions
+	now     func() time.Time
+
+	mutex  sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+	err    error
+
+	compressing sync.WaitGroup
+}
+
+// NewJSONLines constructs a JSON-lines feed appending to the file at the path
+func NewJSONLines(path string, options JSONLinesOptions) (*JSONLines, error) {
+	f := &JSONLines{
+		path:    path,
+		options: options,
+		now:     time.Now,
+	}
+	err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *JSONLines) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.opened = f.now()
+	return nil
+}
+
+// rotatedPath returns the path the file is moved to when it is rotated at the time, a counter is added when
+// a file rotated at the same time already exists
+func (f *JSONLines) rotatedPath(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	base := fmt.Sprintf("%s-%s", strings.TrimSuffix(f.path, ext), t.Format(rotatedTimeFormat))
+	path := base + ext
+	for n := 1; exists(path) || exists(path+".gz"); n++ {
+		path = fmt.Sprintf("%s-%d%s", base, n, ext)
+	}
+	return path
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// rotate moves the file aside and opens a new one, the file is opened again when it cannot be moved so the feed
+// keeps writing. Rotated files are compressed in the background.
+func (f *JSONLines) rotate() error {
+	closeErr := f.file.Close()
+	f.file = nil
+
+	rotated := f.rotatedPath(f.now())
+	renameErr := os.Rename(f.path, rotated)
+
+	err := f.open()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+
+	if f.options.Compress {
+		f.compressing.Add(1)
+		go func() {
+			defer f.compressing.Done()
+			err := compress(rotated)
+			if err != nil {
+				f.fail(err)
+			}
+		}()
+	}
+	return nil
+}
+
+// compress replaces the file at the path with a gzip compressed copy
+func compress(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(out)
+	_, err = io.Copy(writer, in)
+	if err == nil {
+		err = writer.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+func (f *JSONLines) shouldRotate(size int) bool {
+	if f.options.MaxSize > 0 && f.size > 0 && f.size+int64(size) > f.options.MaxSize {
+		return true
+	}
+	return f.options.MaxAge > 0 && f.now().Sub(f.opened) >= f.options.MaxAge
+}
+
+func (f *JSONLines) write(entry *api.FeedEntry) error {
+	if f.file == nil {
+		return ErrClosed
+	}
+	if entry.Timestamp.IsZero() {
+		stamped := *entry
+		stamped.Timestamp = f.now()
+		entry = &stamped
+	}
+
+	record, err := NewRecord(entry)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	// The entry is still written when the file could not be rotated but is open
+	var rotateErr error
+	if f.shouldRotate(len(line)) {
+		rotateErr = f.rotate()
+		if f.file == nil {
+			return rotateErr
+		}
+	}
+
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	if rotateErr != nil {
+		return rotateErr
+	}
+	return err
+}
+
+// PushEntry writes the entry to the file, rotating the file first when it is due
+func (f *JSONLines) PushEntry(entry *api.FeedEntry) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	err := f.write(entry)
+	if err != nil && f.err == nil {
+		f.err = err
+	}
+}
+
+// fail records the error of a background compression if it is the first error
+func (f *JSONLines) fail(err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.err == nil {
+		f.err = err
+	}
+}
+
+// Push writes the entry to the file with the current time
+func (f *JSONLines) Push(entry interface{}) {
+	f.PushEntry(&api.FeedEntry{Message: entry})
+}
+
+// Rotate moves the current file aside and continues writing to a new file
+func (f *JSONLines) Rotate() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file == nil {
+		return ErrClosed
+	}
+	return f.rotate()
+}
+
+// Err returns the first error encountered while writing entries
+func (f *JSONLines) Err() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.err
+}
+
+// Close closes the file, waits for rotated files to be compressed and returns the first error encountered while
+// writing entries
+func (f *JSONLines) Close() error {
+	f.mutex.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mutex.Unlock()
+
+	// No more files are rotated once the file is closed
+	f.compressing.Wait()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	return err
+}
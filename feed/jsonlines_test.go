@@ -0,0 +1,188 @@
+package feed
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+func readRecords(t *testing.T, path string) []*Record {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := make([]*Record, 0)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		record := &Record{}
+		err = json.Unmarshal(scanner.Bytes(), record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestJSONLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Unix(1000, 0).UTC()
+	path := filepath.Join(dir, "feed.jsonl")
+	f, err := NewJSONLines(path, JSONLinesOptions{MaxAge: time.Minute, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.now = func() time.Time { return now }
+	f.opened = now
+
+	f.PushEntry(&api.FeedEntry{
+		Message:         &protos.WildPokemon{EncounterId: 1, Latitude: 1.5},
+		Username:        "trainer",
+		Location:        api.Location{Lat: 1, Lon: 2},
+		StatusCode:      protos.ResponseEnvelope_OK,
+		Timestamp:       now,
+		ServerTimestamp: now.Add(-time.Second),
+		TicketExpiry:    now.Add(time.Hour),
+	})
+	f.Push(&api.LureExpiredEvent{FortID: "pokestop", Timestamp: now})
+
+	now = now.Add(time.Minute)
+	f.Push(&protos.GetPlayerResponse{})
+	err = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := readRecords(t, filepath.Join(dir, "feed-19700101T001740.000.jsonl.gz"))
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records in the rotated file, got %d", len(records))
+	}
+	if records[0].Username != "trainer" || records[0].Location.Lat != 1 {
+		t.Errorf("Expected the entry context to be recorded, got %#v", records[0])
+	}
+
+	entry, err := records[0].Entry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.StatusCode != protos.ResponseEnvelope_OK || !entry.ServerTimestamp.Equal(now.Add(-time.Minute-time.Second)) ||
+		!entry.TicketExpiry.Equal(now.Add(59*time.Minute)) {
+		t.Errorf("Expected the status code, server timestamp and ticket expiry to be recorded, got %#v", entry)
+	}
+
+	message := entry.Message
+	if pokemon, ok := message.(*protos.WildPokemon); !ok || pokemon.EncounterId != 1 || pokemon.Latitude != 1.5 {
+		t.Errorf("Expected the wild Pokémon to be decoded, got %#v", message)
+	}
+	message, err = records[1].Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event, ok := message.(*api.LureExpiredEvent); !ok || event.FortID != "pokestop" {
+		t.Errorf("Expected the lure event to be decoded, got %#v", message)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		t.Errorf("Expected the last entry to be written to a new file")
+	}
+}
+
+func TestJSONLinesRotateAtSameTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Unix(1000, 0).UTC()
+	path := filepath.Join(dir, "feed.jsonl")
+	f, err := NewJSONLines(path, JSONLinesOptions{MaxSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.now = func() time.Time { return now }
+
+	for id := uint64(1); id <= 3; id++ {
+		f.Push(&protos.WildPokemon{EncounterId: id})
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"feed-19700101T001640.000.jsonl", "feed-19700101T001640.000-1.jsonl", "feed.jsonl"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) == 0 {
+			t.Errorf("Expected an entry in %s", name)
+		}
+	}
+}
+
+func TestJSONLinesRotateFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "feed.jsonl")
+	f, err := NewJSONLines(path, JSONLinesOptions{MaxSize: 1, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file cannot be moved aside when it is gone
+	f.Push(&protos.WildPokemon{EncounterId: 1})
+	err = os.Remove(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Push(&protos.WildPokemon{EncounterId: 2})
+	f.Push(&protos.WildPokemon{EncounterId: 3})
+	if err = f.Close(); err == nil {
+		t.Error("Expected the failed rotation to be reported")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		t.Error("Expected entries to be written after the failed rotation")
+	}
+	rotated, err := filepath.Glob(filepath.Join(dir, "feed-*.jsonl.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 1 {
+		t.Errorf("Expected the file to be rotated after the failed rotation, got %v", rotated)
+	}
+}
@@ -0,0 +1,179 @@
+// Package feed provides feeds writing the responses of sessions to files, databases and other services
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	protos "github.com/pogodevorg/POGOProtos-go"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+// eventTypes are the non-protobuf entries pushed by the library which records can be decoded in to
+var eventTypes = map[string]reflect.Type{}
+
+func init() {
+	for _, event := range []interface{}{
+		&api.ChallengeEvent{},
+		&api.PokemonAppearedEvent{},
+		&api.PokemonDespawnedEvent{},
+		&api.LureAddedEvent{},
+		&api.LureExpiredEvent{},
+		&api.GymChangedEvent{},
+		&api.FortCooldownEndedEvent{},
+	} {
+		eventTypes[TypeName(event)] = reflect.TypeOf(event).Elem()
+	}
+}
+
+var marshaler = &jsonpb.Marshaler{}
+
+// Record is a feed entry as it is written to and read from files
+type Record struct {
+	Type            string          `json:"type"`
+	Timestamp       time.Time       `json:"timestamp"`
+	Provider        string          `json:"provider,omitempty"`
+	Username        string          `json:"username,omitempty"`
+	Location        *api.Location   `json:"location,omitempty"`
+	RequestType     string          `json:"request_type,omitempty"`
+	RequestID       uint64          `json:"request_id,omitempty"`
+	StatusCode      string          `json:"status_code,omitempty"`
+	ServerTimestamp *time.Time      `json:"server_timestamp,omitempty"`
+	TicketExpiry    *time.Time      `json:"ticket_expiry,omitempty"`
+	Message         json.RawMessage `json:"message,omitempty"`
+}
+
+// TypeName returns the name of the message type as it appears in records, the protobuf message name like
+// "POGOProtos.Map.Pokemon.WildPokemon" or the Go type name like "api.GymChangedEvent"
+func TypeName(message interface{}) string {
+	if pb, ok := message.(proto.Message); ok {
+		if name := proto.MessageName(pb); name != "" {
+			return name
+		}
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", message), "*")
+}
+
+// newHeader returns the record of the entry without the message
+func newHeader(entry *api.FeedEntry) *Record {
+	record := &Record{
+		Type:      TypeName(entry.Message),
+		Timestamp: entry.Timestamp,
+		Provider:  entry.Provider,
+		Username:  entry.Username,
+		RequestID: entry.RequestID,
+	}
+	if entry.Location != (api.Location{}) {
+		location := entry.Location
+		record.Location = &location
+	}
+	if entry.RequestType != protos.RequestType(0) {
+		record.RequestType = entry.RequestType.String()
+	}
+	if entry.StatusCode != protos.ResponseEnvelope_StatusCode(0) {
+		record.StatusCode = entry.StatusCode.String()
+	}
+	if !entry.ServerTimestamp.IsZero() {
+		serverTimestamp := entry.ServerTimestamp
+		record.ServerTimestamp = &serverTimestamp
+	}
+	if !entry.TicketExpiry.IsZero() {
+		ticketExpiry := entry.TicketExpiry
+		record.TicketExpiry = &ticketExpiry
+	}
+	return record
+}
+
+// NewRecord constructs a record of the entry, protobuf messages are encoded with jsonpb
+func NewRecord(entry *api.FeedEntry) (*Record, error) {
+	var message []byte
+	var err error
+	if pb, ok := entry.Message.(proto.Message); ok {
+		var buf bytes.Buffer
+		err = marshaler.Marshal(&buf, pb)
+		message = buf.Bytes()
+	} else {
+		message, err = json.Marshal(entry.Message)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record := newHeader(entry)
+	record.Message = message
+	return record, nil
+}
+
+func unmarshalJSON(data []byte, message proto.Message) error {
+	return jsonpb.Unmarshal(bytes.NewReader(data), message)
+}
+
+// decodeMessage decodes the data of a message of the type name, protobuf messages are decoded with the
+// function and other messages as JSON
+func decodeMessage(name string, data []byte, unmarshal func([]byte, proto.Message) error) (interface{}, error) {
+	if t := proto.MessageType(name); t != nil {
+		message := reflect.New(t.Elem()).Interface().(proto.Message)
+		err := unmarshal(data, message)
+		if err != nil {
+			return nil, err
+		}
+		return message, nil
+	}
+	if t, ok := eventTypes[name]; ok {
+		event := reflect.New(t).Interface()
+		err := json.Unmarshal(data, event)
+		if err != nil {
+			return nil, err
+		}
+		return event, nil
+	}
+	return nil, ErrUnknownType
+}
+
+// Decode returns the message of the record, the error is ErrUnknownType if the type is not known
+func (r *Record) Decode() (interface{}, error) {
+	return decodeMessage(r.Type, r.Message, unmarshalJSON)
+}
+
+// entry returns the feed entry of the record with the message
+func (r *Record) entry(message interface{}) *api.FeedEntry {
+	entry := &api.FeedEntry{
+		Message:   message,
+		Provider:  r.Provider,
+		Username:  r.Username,
+		RequestID: r.RequestID,
+		Timestamp: r.Timestamp,
+	}
+	if requestType, ok := protos.RequestType_value[r.RequestType]; ok {
+		entry.RequestType = protos.RequestType(requestType)
+	}
+	if statusCode, ok := protos.ResponseEnvelope_StatusCode_value[r.StatusCode]; ok {
+		entry.StatusCode = protos.ResponseEnvelope_StatusCode(statusCode)
+	}
+	if r.Location != nil {
+		entry.Location = *r.Location
+	}
+	if r.ServerTimestamp != nil {
+		entry.ServerTimestamp = *r.ServerTimestamp
+	}
+	if r.TicketExpiry != nil {
+		entry.TicketExpiry = *r.TicketExpiry
+	}
+	return entry
+}
+
+// Entry returns the feed entry of the record
+func (r *Record) Entry() (*api.FeedEntry, error) {
+	message, err := r.Decode()
+	if err != nil {
+		return nil, err
+	}
+	return r.entry(message), nil
+}
@@ -0,0 +1,110 @@
+package feed
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+// EntryReader reads the entries of a recording in order
+type EntryReader interface {
+	// Read returns the next entry, the error is io.EOF at the end of the recording
+	Read() (*api.FeedEntry, error)
+}
+
+// decompress returns a buffered reader of the stream, decompressing it if it is gzip compressed
+func decompress(r io.Reader) (*bufio.Reader, error) {
+	reader := bufio.NewReader(r)
+	magic, err := reader.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		// Streams too short to be compressed are read as is
+		return reader, nil
+	}
+	unzipped, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	return bufio.NewReader(unzipped), nil
+}
+
+// JSONLinesReader reads the entries of a JSON-lines recording
+type JSONLinesReader struct {
+	decoder *json.Decoder
+}
+
+// NewJSONLinesReader constructs a reader of the JSON-lines recording, gzip compressed recordings are decompressed
+func NewJSONLinesReader(r io.Reader) (*JSONLinesReader, error) {
+	reader, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLinesReader{decoder: json.NewDecoder(reader)}, nil
+}
+
+// Read returns the next entry, the error is io.EOF at the end of the recording and ErrUnknownType for
+// messages of unknown types, which can be skipped
+func (j *JSONLinesReader) Read() (*api.FeedEntry, error) {
+	record := &Record{}
+	err := j.decoder.Decode(record)
+	if err != nil {
+		return nil, err
+	}
+	return record.Entry()
+}
+
+// Replay pushes the entries of the recording to the feed until the end of the recording or the context is
+// done. Entries are pushed as fast as possible when the speed is zero, otherwise the time between the
+// entries is kept divided by the speed. Entries of unknown types are skipped. The number of pushed entries
+// is returned.
+func Replay(ctx context.Context, reader EntryReader, feed api.Feed, speed float64) (int, error) {
+	entryFeed, ok := feed.(api.EntryFeed)
+	if !ok {
+		entryFeed = api.NewFeedAdapter(feed)
+	}
+
+	var first time.Time
+	var started time.Time
+	pushed := 0
+	for {
+		entry, err := reader.Read()
+		if err == io.EOF {
+			return pushed, nil
+		}
+		if err == ErrUnknownType {
+			continue
+		}
+		if err != nil {
+			return pushed, err
+		}
+
+		if speed > 0 && !entry.Timestamp.IsZero() {
+			if first.IsZero() {
+				first = entry.Timestamp
+				started = time.Now()
+			}
+			offset := time.Duration(float64(entry.Timestamp.Sub(first)) / speed)
+			if wait := started.Add(offset).Sub(time.Now()); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return pushed, ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return pushed, ctx.Err()
+		default:
+		}
+		entryFeed.PushEntry(entry)
+		pushed++
+	}
+}
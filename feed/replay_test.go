@@ -0,0 +1,116 @@
+package feed
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+type recordFeed struct {
+	entries []*api.FeedEntry
+}
+
+func (f *recordFeed) PushEntry(entry *api.FeedEntry) {
+	f.entries = append(f.entries, entry)
+}
+
+func (f *recordFeed) Push(entry interface{}) {
+	f.PushEntry(&api.FeedEntry{Message: entry})
+}
+
+func recording() []*api.FeedEntry {
+	return []*api.FeedEntry{
+		{Message: &protos.WildPokemon{EncounterId: 1}, Username: "trainer", Timestamp: time.Unix(1000, 0)},
+		{Message: &api.LureExpiredEvent{FortID: "pokestop"}, Timestamp: time.Unix(1001, 0)},
+		{Message: &protos.GetPlayerResponse{Success: true}, Timestamp: time.Unix(1002, 0)},
+	}
+}
+
+func checkReplay(t *testing.T, f *recordFeed, pushed int) {
+	if pushed != 3 || len(f.entries) != 3 {
+		t.Fatalf("Expected 3 replayed entries, got %d", len(f.entries))
+	}
+	if pokemon, ok := f.entries[0].Message.(*protos.WildPokemon); !ok || pokemon.EncounterId != 1 || f.entries[0].Username != "trainer" {
+		t.Errorf("Expected the wild Pokémon of the trainer, got %#v", f.entries[0])
+	}
+	if event, ok := f.entries[1].Message.(*api.LureExpiredEvent); !ok || event.FortID != "pokestop" {
+		t.Errorf("Expected the lure event, got %#v", f.entries[1].Message)
+	}
+	if !f.entries[2].Timestamp.Equal(time.Unix(1002, 0)) {
+		t.Errorf("Expected the original timestamp, got %v", f.entries[2].Timestamp)
+	}
+}
+
+func TestReplayBinary(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewBinary(&buf)
+	for _, entry := range recording() {
+		writer.PushEntry(entry)
+	}
+	err := writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewBinaryReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &recordFeed{}
+	pushed, err := Replay(context.Background(), reader, f, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReplay(t, f, pushed)
+}
+
+func TestBinaryFrameTooLarge(t *testing.T) {
+	prefix := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(prefix, 1<<62)
+	reader, err := NewBinaryReader(bytes.NewReader(prefix[:n]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = reader.Read()
+	if err != ErrFrameTooLarge {
+		t.Errorf("Expected the frame to be too large, got %v", err)
+	}
+}
+
+func TestReplayJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	zipped := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(zipped)
+	for _, entry := range recording() {
+		record, err := NewRecord(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoder.Encode(record)
+	}
+	encoder.Encode(&Record{Type: "Unknown", Message: json.RawMessage("{}")})
+	zipped.Close()
+
+	reader, err := NewJSONLinesReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &recordFeed{}
+	started := time.Now()
+	pushed, err := Replay(context.Background(), reader, f, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected the 2 seconds between the entries to take 20ms at 100 times the speed, took %v", elapsed)
+	}
+	checkReplay(t, f, pushed)
+}
@@ -0,0 +1,435 @@
+package feed
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+	"golang.org/x/net/websocket"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+// Subscription filters the entries streamed to a client by type name and bounding box, entries
+// without a location are left out when a bounding box is set
+type Subscription struct {
+	Types []string `json:"types,omitempty"`
+	BBox  string   `json:"bbox,omitempty"`
+}
+
+type filter struct {
+	types map[string]bool
+	box   *api.BoundingBox
+}
+
+func newFilter(subscription *Subscription) (*filter, error) {
+	f := &filter{types: make(map[string]bool)}
+	for _, t := range subscription.Types {
+		if t = strings.TrimSpace(t); t != "" {
+			f.types[t] = true
+		}
+	}
+	if subscription.BBox != "" {
+		box, err := api.ParseBoundingBox(subscription.BBox)
+		if err != nil {
+			return nil, err
+		}
+		f.box = box
+	}
+	return f, nil
+}
+
+func (f *filter) accepts(name string, location *api.Location) bool {
+	if len(f.types) > 0 && !f.types[name] {
+		return false
+	}
+	return f.box == nil || (location != nil && f.box.Contains(location))
+}
+
+// entryLocation returns the location of the object in the message, or the location the entry was received at
+func entryLocation(entry *api.FeedEntry) *api.Location {
+	switch m := entry.Message.(type) {
+	case *protos.WildPokemon:
+		return &api.Location{Lat: m.Latitude, Lon: m.Longitude}
+	case *protos.MapPokemon:
+		return &api.Location{Lat: m.Latitude, Lon: m.Longitude}
+	case *protos.FortData:
+		return &api.Location{Lat: m.Latitude, Lon: m.Longitude}
+	case *api.PokemonAppearedEvent:
+		return &api.Location{Lat: m.Pokemon.Pokemon.Latitude, Lon: m.Pokemon.Pokemon.Longitude}
+	case *api.PokemonDespawnedEvent:
+		return &api.Location{Lat: m.Pokemon.Pokemon.Latitude, Lon: m.Pokemon.Pokemon.Longitude}
+	case *api.GymChangedEvent:
+		return &api.Location{Lat: m.Current.Latitude, Lon: m.Current.Longitude}
+	case *api.FortCooldownEndedEvent:
+		return &api.Location{Lat: m.Fort.Latitude, Lon: m.Fort.Longitude}
+	case *api.LureAddedEvent:
+		if m.Fort != nil {
+			return &api.Location{Lat: m.Fort.Latitude, Lon: m.Fort.Longitude}
+		}
+	case *api.LureExpiredEvent:
+		if m.Fort != nil {
+			return &api.Location{Lat: m.Fort.Latitude, Lon: m.Fort.Longitude}
+		}
+	case *api.ChallengeEvent:
+		return &m.Location
+	}
+	if entry.Location != (api.Location{}) {
+		location := entry.Location
+		return &location
+	}
+	return nil
+}
+
+type serverClient struct {
+	mutex  sync.RWMutex
+	filter *filter
+	queue  chan *Record
+}
+
+func (c *serverClient) setFilter(f *filter) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.filter = f
+}
+
+func (c *serverClient) accepts(name string, location *api.Location) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.filter.accepts(name, location)
+}
+
+// Server is a feed streaming entries as records to clients connected over WebSocket or Server-Sent Events.
+// Map objects responses are also streamed as their wild Pokémon and forts.
+// Clients subscribe with the "types" and "bbox" query parameters, WebSocket clients can change their
+// subscription by sending it as a JSON object. Browsers may only connect from the same origin or an allowed origin.
+type Server struct {
+	bufferSize int
+
+	mutex    sync.RWMutex
+	clients  map[*serverClient]struct{}
+	origins  map[string]bool
+	listener net.Listener
+	closed   chan struct{}
+
+	dropped uint64
+}
+
+// NewServer constructs a server buffering up to the number of records for each client, records are
+// dropped for clients that are not keeping up
+func NewServer(bufferSize int) *Server {
+	return &Server{
+		bufferSize: bufferSize,
+		clients:    make(map[*serverClient]struct{}),
+		origins:    make(map[string]bool),
+		closed:     make(chan struct{}),
+	}
+}
+
+// SetAllowedOrigins sets the origins of the web pages allowed to connect besides the origin of the server itself,
+// "*" allows all origins
+func (s *Server) SetAllowedOrigins(origins []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.origins = make(map[string]bool)
+	for _, origin := range origins {
+		s.origins[origin] = true
+	}
+}
+
+// allowsOrigin tells if a client with the origin may connect, clients that are not browsers send no origin
+func (s *Server) allowsOrigin(origin string, r *http.Request) bool {
+	if origin == "" {
+		return true
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.origins["*"] || s.origins[origin] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// handshake rejects WebSocket connections from origins that are not allowed
+func (s *Server) handshake(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if !s.allowsOrigin(origin, r) {
+		return fmt.Errorf("Origin \"%s\" is not allowed", origin)
+	}
+	return nil
+}
+
+// Handler returns the handler serving WebSocket connections at "/ws" and Server-Sent Events at "/events"
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/ws", websocket.Server{Handler: s.serveWebSocket, Handshake: s.handshake})
+	mux.HandleFunc("/events", s.serveEvents)
+	return mux
+}
+
+// Listen starts listening on the address, the error is ErrClosed when the server has been closed
+func (s *Server) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	select {
+	case <-s.closed:
+		listener.Close()
+		return ErrClosed
+	default:
+	}
+	s.listener = listener
+	return nil
+}
+
+// Serve serves the handler on the address listened on with Listen until the server is closed
+func (s *Server) Serve() error {
+	s.mutex.RLock()
+	listener := s.listener
+	s.mutex.RUnlock()
+	if listener == nil {
+		return ErrClosed
+	}
+
+	err := http.Serve(listener, s.Handler())
+	select {
+	case <-s.closed:
+		return nil
+	default:
+		return err
+	}
+}
+
+// ListenAndServe serves the handler on the address until the server is closed
+func (s *Server) ListenAndServe(addr string) error {
+	err := s.Listen(addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve()
+}
+
+// Close stops listening and disconnects all clients
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	select {
+	case <-s.closed:
+		return nil
+	default:
+	}
+	close(s.closed)
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+// Dropped returns the number of records dropped for clients that were not keeping up
+func (s *Server) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *Server) subscribe(subscription *Subscription) (*serverClient, error) {
+	f, err := newFilter(subscription)
+	if err != nil {
+		return nil, err
+	}
+	c := &serverClient{
+		filter: f,
+		queue:  make(chan *Record, s.bufferSize),
+	}
+	s.mutex.Lock()
+	s.clients[c] = struct{}{}
+	s.mutex.Unlock()
+	return c, nil
+}
+
+func (s *Server) unsubscribe(c *serverClient) {
+	s.mutex.Lock()
+	delete(s.clients, c)
+	s.mutex.Unlock()
+}
+
+func requestSubscription(r *http.Request) *Subscription {
+	subscription := &Subscription{BBox: r.URL.Query().Get("bbox")}
+	if types := r.URL.Query().Get("types"); types != "" {
+		subscription.Types = strings.Split(types, ",")
+	}
+	return subscription
+}
+
+func (s *Server) serveWebSocket(ws *websocket.Conn) {
+	defer ws.Close()
+	c, err := s.subscribe(requestSubscription(ws.Request()))
+	if err != nil {
+		websocket.JSON.Send(ws, map[string]string{"error": err.Error()})
+		return
+	}
+	defer s.unsubscribe(c)
+
+	// Subscription changes are read until the client disconnects
+	disconnected := make(chan struct{})
+	go func() {
+		defer close(disconnected)
+		for {
+			subscription := &Subscription{}
+			err := websocket.JSON.Receive(ws, subscription)
+			if err != nil {
+				return
+			}
+			f, err := newFilter(subscription)
+			if err != nil {
+				websocket.JSON.Send(ws, map[string]string{"error": err.Error()})
+				continue
+			}
+			c.setFilter(f)
+		}
+	}()
+
+	for {
+		select {
+		case record := <-c.queue:
+			err = websocket.JSON.Send(ws, record)
+			if err != nil {
+				return
+			}
+		case <-disconnected:
+			return
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	origin := r.Header.Get("Origin")
+	if !s.allowsOrigin(origin, r) {
+		http.Error(w, fmt.Sprintf("Origin \"%s\" is not allowed", origin), http.StatusForbidden)
+		return
+	}
+	c, err := s.subscribe(requestSubscription(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer s.unsubscribe(c)
+
+	if origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Vary", "Origin")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case record := <-c.queue:
+			data, err := json.Marshal(record)
+			if err != nil {
+				continue
+			}
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", record.Type, data)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// split returns the entry followed by an entry for each wild Pokémon and fort of a map objects response, so the
+// clients can subscribe to the objects within their bounding box
+func split(entry *api.FeedEntry) []*api.FeedEntry {
+	entries := []*api.FeedEntry{entry}
+	mapObjects, ok := entry.Message.(*protos.GetMapObjectsResponse)
+	if !ok {
+		return entries
+	}
+
+	snapshot := api.NewMapSnapshot(mapObjects, &entry.Location, entry.Timestamp)
+	objects := make([]interface{}, 0, len(snapshot.WildPokemons)+len(snapshot.Forts))
+	for _, pokemon := range snapshot.WildPokemons {
+		objects = append(objects, pokemon.Pokemon)
+	}
+	for _, fort := range snapshot.Forts {
+		objects = append(objects, fort.Fort)
+	}
+	for _, object := range objects {
+		split := *entry
+		split.Message = object
+		entries = append(entries, &split)
+	}
+	return entries
+}
+
+// PushEntry streams the entry to the clients subscribed to it, map objects responses are streamed as is and
+// split in to their wild Pokémon and forts
+func (s *Server) PushEntry(entry *api.FeedEntry) {
+	if entry.Timestamp.IsZero() {
+		stamped := *entry
+		stamped.Timestamp = time.Now()
+		entry = &stamped
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if len(s.clients) == 0 {
+		return
+	}
+
+	for _, e := range split(entry) {
+		s.stream(e)
+	}
+}
+
+// stream queues the record of the entry for the clients subscribed to it
+func (s *Server) stream(entry *api.FeedEntry) {
+	name := TypeName(entry.Message)
+	location := entryLocation(entry)
+	var record *Record
+	for c := range s.clients {
+		if !c.accepts(name, location) {
+			continue
+		}
+		if record == nil {
+			var err error
+			record, err = NewRecord(entry)
+			if err != nil {
+				return
+			}
+		}
+		select {
+		case c.queue <- record:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// Push streams the entry to the clients subscribed to it
+func (s *Server) Push(entry interface{}) {
+	s.PushEntry(&api.FeedEntry{Message: entry})
+}
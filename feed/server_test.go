@@ -0,0 +1,205 @@
+package feed
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+	"golang.org/x/net/websocket"
+)
+
+// waitForClients waits until the number of clients are connected to the server
+func waitForClients(t *testing.T, s *Server, n int) {
+	for i := 0; i < 100; i++ {
+		s.mutex.RLock()
+		connected := len(s.clients)
+		s.mutex.RUnlock()
+		if connected == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d clients to connect", n)
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer(10)
+	defer s.Close()
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	pokemon := &protos.WildPokemon{}
+	response, err := http.Get(ts.URL + "/events?types=" + TypeName(pokemon))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	ws, err := websocket.Dial(strings.Replace(ts.URL, "http", "ws", 1)+"/ws?bbox=0,0,1,1", "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	waitForClients(t, s, 2)
+
+	s.Push(&protos.GetPlayerResponse{})
+	s.Push(&protos.WildPokemon{EncounterId: 1, Latitude: 2, Longitude: 2})
+	s.Push(&protos.WildPokemon{EncounterId: 2, Latitude: 0.5, Longitude: 0.5})
+
+	reader := bufio.NewReader(response.Body)
+	events := make([]string, 0)
+	for len(events) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			events = append(events, line)
+		}
+	}
+	if !strings.Contains(events[0], `"encounterId":"1"`) || !strings.Contains(events[1], `"encounterId":"2"`) {
+		t.Errorf("Expected only the Pokémon to be streamed over events, got %v", events)
+	}
+
+	record := &Record{}
+	err = websocket.JSON.Receive(ws, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := record.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pokemon, ok := message.(*protos.WildPokemon); !ok || pokemon.EncounterId != 2 {
+		t.Errorf("Expected only the Pokémon within the bounding box over the WebSocket, got %#v", message)
+	}
+
+	err = websocket.JSON.Send(ws, &Subscription{Types: []string{TypeName(&protos.GetPlayerResponse{})}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForFilter(t, s, func(f *filter) bool { return f.types[TypeName(&protos.GetPlayerResponse{})] })
+	s.Push(&protos.WildPokemon{EncounterId: 3, Latitude: 0.5, Longitude: 0.5})
+	s.Push(&protos.GetPlayerResponse{})
+	err = websocket.JSON.Receive(ws, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Type != TypeName(&protos.GetPlayerResponse{}) {
+		t.Errorf("Expected the changed subscription to only stream players, got %s", record.Type)
+	}
+}
+
+// waitForFilter waits until a client of the server has a filter matching the function
+func waitForFilter(t *testing.T, s *Server, match func(*filter) bool) {
+	for i := 0; i < 100; i++ {
+		s.mutex.RLock()
+		for c := range s.clients {
+			c.mutex.RLock()
+			matched := match(c.filter)
+			c.mutex.RUnlock()
+			if matched {
+				s.mutex.RUnlock()
+				return
+			}
+		}
+		s.mutex.RUnlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Expected a client to change its subscription")
+}
+
+func TestServerMapObjects(t *testing.T) {
+	s := NewServer(10)
+	defer s.Close()
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	ws, err := websocket.Dial(strings.Replace(ts.URL, "http", "ws", 1)+"/ws?types="+TypeName(&protos.WildPokemon{})+"&bbox=0,0,1,1", "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	waitForClients(t, s, 1)
+
+	s.Push(&protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{
+		S2CellId: 1,
+		WildPokemons: []*protos.WildPokemon{
+			{EncounterId: 1, Latitude: 2, Longitude: 2},
+			{EncounterId: 2, Latitude: 0.5, Longitude: 0.5},
+		},
+		Forts: []*protos.FortData{{Id: "gym", Latitude: 0.5, Longitude: 0.5}},
+	}}})
+	s.Push(&protos.WildPokemon{EncounterId: 3, Latitude: 0.5, Longitude: 0.5})
+
+	for _, encounterID := range []uint64{2, 3} {
+		record := &Record{}
+		err = websocket.JSON.Receive(ws, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		message, err := record.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pokemon, ok := message.(*protos.WildPokemon); !ok || pokemon.EncounterId != encounterID {
+			t.Errorf("Expected Pokémon %d of the map objects within the bounding box, got %#v", encounterID, message)
+		}
+	}
+}
+
+func TestServerOrigins(t *testing.T) {
+	s := NewServer(10)
+	defer s.Close()
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	wsURL := strings.Replace(ts.URL, "http", "ws", 1) + "/ws"
+
+	_, err := websocket.Dial(wsURL, "", "http://app.example.com")
+	if err == nil {
+		t.Error("Expected a WebSocket from another origin to be rejected")
+	}
+
+	s.SetAllowedOrigins([]string{"http://app.example.com"})
+	ws, err := websocket.Dial(wsURL, "", "http://app.example.com")
+	if err != nil {
+		t.Fatalf("Expected a WebSocket from an allowed origin to connect, got %s", err)
+	}
+	ws.Close()
+
+	events := func(origin string) *http.Response {
+		request, err := http.NewRequest("GET", ts.URL+"/events", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("Origin", origin)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+	response := events("http://app.example.com")
+	response.Body.Close()
+	if allowed := response.Header.Get("Access-Control-Allow-Origin"); allowed != "http://app.example.com" {
+		t.Errorf("Expected events to be allowed for the origin, got %q", allowed)
+	}
+	response = events("http://other.example.com")
+	response.Body.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected events from another origin to be forbidden, got status %d", response.StatusCode)
+	}
+}
+
+func TestServerClosedBeforeListening(t *testing.T) {
+	s := NewServer(10)
+	s.Close()
+	if err := s.Listen("127.0.0.1:0"); err != ErrClosed {
+		t.Errorf("Expected a closed server not to listen, got %v", err)
+	}
+}
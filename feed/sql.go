@@ -0,0 +1,332 @@
+package feed
+
+import (
+	"bytes"
+	"database/sql"
+	"strconv"
+	"sync"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+// Dialect is the SQL dialect spoken by the database
+type Dialect int
+
+const (
+	// DialectSQLite is for SQLite 3.24 and later
+	DialectSQLite Dialect = iota
+	// DialectPostgres is for PostgreSQL 9.5 and later
+	DialectPostgres
+)
+
+// migrations are the statements creating the schema, each version is applied once in a transaction.
+// Timestamps are stored as milliseconds since the epoch, encounter and cell IDs as the bits of signed integers.
+var migrations = [][]string{
+	{
+		`CREATE TABLE pokemon_sightings (
+			encounter_id BIGINT PRIMARY KEY,
+			pokemon_id INTEGER NOT NULL,
+			spawn_point_id TEXT NOT NULL,
+			cell_id BIGINT NOT NULL,
+			latitude DOUBLE PRECISION NOT NULL,
+			longitude DOUBLE PRECISION NOT NULL,
+			despawn_time BIGINT,
+			first_seen BIGINT NOT NULL,
+			last_seen BIGINT NOT NULL
+		)`,
+		`CREATE INDEX pokemon_sightings_last_seen ON pokemon_sightings (last_seen)`,
+		`CREATE TABLE forts (
+			id TEXT PRIMARY KEY,
+			type INTEGER NOT NULL,
+			cell_id BIGINT NOT NULL,
+			latitude DOUBLE PRECISION NOT NULL,
+			longitude DOUBLE PRECISION NOT NULL,
+			last_modified BIGINT NOT NULL,
+			lure_expires BIGINT,
+			cooldown_complete BIGINT,
+			first_seen BIGINT NOT NULL,
+			last_seen BIGINT NOT NULL
+		)`,
+		`CREATE TABLE gym_states (
+			fort_id TEXT NOT NULL,
+			last_modified BIGINT NOT NULL,
+			team INTEGER NOT NULL,
+			gym_points BIGINT NOT NULL,
+			guard_pokemon_id INTEGER NOT NULL,
+			guard_pokemon_cp INTEGER NOT NULL,
+			observed_at BIGINT NOT NULL,
+			PRIMARY KEY (fort_id, last_modified)
+		)`,
+		`CREATE TABLE player_snapshots (
+			username TEXT NOT NULL,
+			observed_at BIGINT NOT NULL,
+			team INTEGER NOT NULL,
+			data TEXT NOT NULL,
+			PRIMARY KEY (username, observed_at)
+		)`,
+	},
+}
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	applied_at BIGINT NOT NULL
+)`
+
+const upsertSighting = `INSERT INTO pokemon_sightings
+	(encounter_id, pokemon_id, spawn_point_id, cell_id, latitude, longitude, despawn_time, first_seen, last_seen)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (encounter_id) DO UPDATE SET
+		despawn_time = COALESCE(excluded.despawn_time, pokemon_sightings.despawn_time),
+		last_seen = excluded.last_seen`
+
+const upsertFort = `INSERT INTO forts
+	(id, type, cell_id, latitude, longitude, last_modified, lure_expires, cooldown_complete, first_seen, last_seen)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (id) DO UPDATE SET
+		type = excluded.type,
+		cell_id = excluded.cell_id,
+		latitude = excluded.latitude,
+		longitude = excluded.longitude,
+		last_modified = excluded.last_modified,
+		lure_expires = excluded.lure_expires,
+		cooldown_complete = excluded.cooldown_complete,
+		last_seen = excluded.last_seen`
+
+const insertGymState = `INSERT INTO gym_states
+	(fort_id, last_modified, team, gym_points, guard_pokemon_id, guard_pokemon_cp, observed_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (fort_id, last_modified) DO NOTHING`
+
+const upsertPlayerSnapshot = `INSERT INTO player_snapshots
+	(username, observed_at, team, data)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT (username, observed_at) DO UPDATE SET
+		team = excluded.team,
+		data = excluded.data`
+
+// rebind replaces the ? placeholders of the query with the placeholders of the dialect
+func (d Dialect) rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+	var buf bytes.Buffer
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			buf.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		buf.WriteRune(c)
+	}
+	return buf.String()
+}
+
+func toMs(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func nullMs(ms int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: ms, Valid: ms > 0}
+}
+
+// SQL is a feed persisting wild Pokémon sightings, forts, gym states and player snapshots in a database.
+// The database driver is left to the application, e.g. a pure Go SQLite driver or lib/pq for PostgreSQL.
+type SQL struct {
+	db      *sql.DB
+	dialect Dialect
+
+	mutex sync.Mutex
+	err   error
+}
+
+// NewSQL constructs an SQL feed and migrates the schema of the database
+func NewSQL(db *sql.DB, dialect Dialect) (*SQL, error) {
+	s := &SQL{
+		db:      db,
+		dialect: dialect,
+	}
+	err := s.Migrate()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Migrate applies the schema versions the database is missing
+func (s *SQL) Migrate() error {
+	_, err := s.db.Exec(createMigrationsTable)
+	if err != nil {
+		return err
+	}
+
+	var version int
+	err = s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	for ; version < len(migrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		for _, statement := range migrations[version] {
+			_, err = tx.Exec(statement)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		_, err = tx.Exec(s.dialect.rebind(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`),
+			version+1, toMs(time.Now()))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQL) saveMap(tx *sql.Tx, snapshot *api.MapSnapshot) error {
+	observed := toMs(snapshot.Timestamp)
+	for _, pokemon := range snapshot.WildPokemons {
+		var despawn sql.NullInt64
+		if !pokemon.DespawnTime.IsZero() {
+			despawn = sql.NullInt64{Int64: toMs(pokemon.DespawnTime), Valid: true}
+		}
+		_, err := tx.Exec(s.dialect.rebind(upsertSighting),
+			int64(pokemon.Pokemon.EncounterId),
+			int64(pokemon.Pokemon.GetPokemonData().GetPokemonId()),
+			pokemon.Pokemon.SpawnPointId,
+			int64(pokemon.CellID),
+			pokemon.Pokemon.Latitude,
+			pokemon.Pokemon.Longitude,
+			despawn,
+			observed,
+			observed,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, f := range snapshot.Forts {
+		fort := f.Fort
+		_, err := tx.Exec(s.dialect.rebind(upsertFort),
+			fort.Id,
+			int64(fort.Type),
+			int64(f.CellID),
+			fort.Latitude,
+			fort.Longitude,
+			fort.LastModifiedTimestampMs,
+			nullMs(fort.GetLureInfo().GetLureExpiresTimestampMs()),
+			nullMs(fort.CooldownCompleteTimestampMs),
+			observed,
+			observed,
+		)
+		if err != nil {
+			return err
+		}
+		if fort.Type != protos.FortType_GYM {
+			continue
+		}
+		_, err = tx.Exec(s.dialect.rebind(insertGymState),
+			fort.Id,
+			fort.LastModifiedTimestampMs,
+			int64(fort.OwnedByTeam),
+			fort.GymPoints,
+			int64(fort.GuardPokemonId),
+			int64(fort.GuardPokemonCp),
+			observed,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQL) savePlayer(tx *sql.Tx, player *protos.GetPlayerResponse, timestamp time.Time) error {
+	data := player.GetPlayerData()
+	if data == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	err := marshaler.Marshal(&buf, data)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(s.dialect.rebind(upsertPlayerSnapshot),
+		data.Username,
+		toMs(timestamp),
+		int64(data.Team),
+		buf.String(),
+	)
+	return err
+}
+
+func (s *SQL) save(entry *api.FeedEntry) error {
+	timestamp := entry.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	var mapObjects *protos.GetMapObjectsResponse
+	var player *protos.GetPlayerResponse
+	switch m := entry.Message.(type) {
+	case *protos.GetMapObjectsResponse:
+		mapObjects = m
+	case *protos.WildPokemon:
+		mapObjects = &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{WildPokemons: []*protos.WildPokemon{m}}}}
+	case *protos.GetPlayerResponse:
+		player = m
+	default:
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if mapObjects != nil {
+		location := entry.Location
+		err = s.saveMap(tx, api.NewMapSnapshot(mapObjects, &location, timestamp))
+	} else {
+		err = s.savePlayer(tx, player, timestamp)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// PushEntry stores the map objects, wild Pokémon or player of the entry, other entries are ignored
+func (s *SQL) PushEntry(entry *api.FeedEntry) {
+	err := s.save(entry)
+	if err != nil {
+		s.mutex.Lock()
+		s.err = err
+		s.mutex.Unlock()
+	}
+}
+
+// Push stores the map objects, wild Pokémon or player with the current time
+func (s *SQL) Push(entry interface{}) {
+	s.PushEntry(&api.FeedEntry{Message: entry})
+}
+
+// Err returns the last error encountered while storing entries
+func (s *SQL) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
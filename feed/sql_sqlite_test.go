@@ -0,0 +1,99 @@
+//go:build sqlite
+// +build sqlite
+
+package feed
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	protos "github.com/pogodevorg/POGOProtos-go"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+// TestSQLite runs the statements against a real SQLite database, run it with "go test -tags sqlite"
+func TestSQLite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "feed.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s, err := NewSQL(db, DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Migrate()
+	if err != nil {
+		t.Fatalf("Expected migrating again to do nothing, got %s", err)
+	}
+
+	mapObjects := func(timeTillHidden int32, team protos.TeamColor, lastModified int64) *protos.GetMapObjectsResponse {
+		return &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{
+			S2CellId: 1,
+			WildPokemons: []*protos.WildPokemon{{
+				EncounterId:      1,
+				SpawnPointId:     "spawn",
+				TimeTillHiddenMs: timeTillHidden,
+				PokemonData:      &protos.PokemonData{PokemonId: protos.PokemonId_PIDGEY},
+			}},
+			Forts: []*protos.FortData{{
+				Id:                      "gym",
+				Type:                    protos.FortType_GYM,
+				OwnedByTeam:             team,
+				LastModifiedTimestampMs: lastModified,
+			}},
+		}}}
+	}
+	s.PushEntry(&api.FeedEntry{Message: mapObjects(60000, protos.TeamColor_RED, 900000), Timestamp: time.Unix(1000, 0)})
+	s.PushEntry(&api.FeedEntry{Message: mapObjects(0, protos.TeamColor_RED, 900000), Timestamp: time.Unix(1030, 0)})
+	s.PushEntry(&api.FeedEntry{Message: mapObjects(0, protos.TeamColor_BLUE, 1020000), Timestamp: time.Unix(1040, 0)})
+	s.PushEntry(&api.FeedEntry{
+		Message:   &protos.GetPlayerResponse{PlayerData: &protos.PlayerData{Username: "trainer", Team: protos.TeamColor_BLUE}},
+		Timestamp: time.Unix(1000, 0),
+	})
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+
+	var despawn, firstSeen, lastSeen int64
+	err = db.QueryRow(`SELECT despawn_time, first_seen, last_seen FROM pokemon_sightings WHERE encounter_id = 1`).
+		Scan(&despawn, &firstSeen, &lastSeen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if despawn != 1060000 || firstSeen != 1000000 || lastSeen != 1040000 {
+		t.Errorf("Expected the sighting to keep its despawn time and first seen time, got %d, %d and %d", despawn, firstSeen, lastSeen)
+	}
+
+	var states int
+	err = db.QueryRow(`SELECT COUNT(*) FROM gym_states WHERE fort_id = 'gym'`).Scan(&states)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if states != 2 {
+		t.Errorf("Expected 2 gym states, got %d", states)
+	}
+
+	var username string
+	var team int
+	err = db.QueryRow(`SELECT username, team FROM player_snapshots`).Scan(&username, &team)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "trainer" || team != int(protos.TeamColor_BLUE) {
+		t.Errorf("Expected the player snapshot to be stored, got %s and %d", username, team)
+	}
+}
@@ -0,0 +1,127 @@
+package feed
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+// recordDriver is a database driver recording the executed statements and their arguments
+type recordDriver struct {
+	mutex      sync.Mutex
+	statements []string
+	args       [][]driver.Value
+}
+
+func (d *recordDriver) Open(name string) (driver.Conn, error) { return &recordConn{d}, nil }
+
+type recordConn struct{ driver *recordDriver }
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordStmt{c.driver, query}, nil
+}
+func (c *recordConn) Close() error              { return nil }
+func (c *recordConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *recordConn) Commit() error             { return nil }
+func (c *recordConn) Rollback() error           { return nil }
+
+type recordStmt struct {
+	driver *recordDriver
+	query  string
+}
+
+func (s *recordStmt) Close() error  { return nil }
+func (s *recordStmt) NumInput() int { return -1 }
+func (s *recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.driver.mutex.Lock()
+	defer s.driver.mutex.Unlock()
+	s.driver.statements = append(s.driver.statements, s.query)
+	s.driver.args = append(s.driver.args, args)
+	return driver.RowsAffected(1), nil
+}
+func (s *recordStmt) Query(args []driver.Value) (driver.Rows, error) { return &versionRows{}, nil }
+
+// versionRows is a single row telling that no schema version has been applied
+type versionRows struct{ done bool }
+
+func (r *versionRows) Columns() []string { return []string{"version"} }
+func (r *versionRows) Close() error      { return nil }
+func (r *versionRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(0)
+	return nil
+}
+
+var recorder = &recordDriver{}
+
+func init() {
+	sql.Register("record", recorder)
+}
+
+func TestRebind(t *testing.T) {
+	query := "INSERT INTO t (a, b) VALUES (?, ?)"
+	if DialectSQLite.rebind(query) != query {
+		t.Errorf("Expected SQLite placeholders to be kept, got %s", DialectSQLite.rebind(query))
+	}
+	if rebound := DialectPostgres.rebind(query); rebound != "INSERT INTO t (a, b) VALUES ($1, $2)" {
+		t.Errorf("Expected numbered placeholders, got %s", rebound)
+	}
+}
+
+func TestSQL(t *testing.T) {
+	db, err := sql.Open("record", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSQL(db, DialectPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder.mutex.Lock()
+	migrated := len(recorder.statements)
+	recorder.mutex.Unlock()
+	if migrated != len(migrations[0])+2 {
+		t.Errorf("Expected the migrations table, the first version and its record to be created, got %d statements", migrated)
+	}
+
+	s.PushEntry(&api.FeedEntry{
+		Message: &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{
+			S2CellId:     1,
+			WildPokemons: []*protos.WildPokemon{{EncounterId: 1, TimeTillHiddenMs: 60000}},
+			Forts:        []*protos.FortData{{Id: "gym", Type: protos.FortType_GYM, OwnedByTeam: protos.TeamColor_RED}},
+		}}},
+		Timestamp: time.Unix(1000, 0),
+	})
+	s.Push(&protos.GetPlayerResponse{PlayerData: &protos.PlayerData{Username: "trainer"}})
+	s.Push(&protos.GetInventoryResponse{})
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	statements := recorder.statements[migrated:]
+	if len(statements) != 4 {
+		t.Fatalf("Expected a sighting, a fort, a gym state and a player snapshot, got %d statements", len(statements))
+	}
+	for idx, table := range []string{"pokemon_sightings", "forts", "gym_states", "player_snapshots"} {
+		if !strings.Contains(statements[idx], "INSERT INTO "+table) || strings.Contains(statements[idx], "?") {
+			t.Errorf("Expected a rebound insert in to %s, got %s", table, statements[idx])
+		}
+	}
+	if despawn := recorder.args[migrated][6]; despawn != int64(1060000) {
+		t.Errorf("Expected the despawn time to be stored, got %v", despawn)
+	}
+}
@@ -0,0 +1,292 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+// Endpoint is an HTTP endpoint receiving the entries of a webhook feed
+type Endpoint struct {
+	URL string
+	// Types are the type names of the messages sent to the endpoint as they appear in records, e.g.
+	// "POGOProtos.Map.Pokemon.WildPokemon" or "api.PokemonAppearedEvent". All messages are sent when empty.
+	Types []string
+	// Template renders the body from the batch of WebhookItems, the batch is sent as a JSON array of records when nil
+	Template *template.Template
+	// ContentType of the body, defaults to "application/json"
+	ContentType string
+}
+
+// WebhookItem is an entry of a batch as it is passed to the template of an endpoint
+type WebhookItem struct {
+	Type      string
+	Timestamp time.Time
+	Message   interface{}
+	Entry     *api.FeedEntry
+}
+
+// WebhookOptions configures the batching, retrying and queueing of a webhook feed
+type WebhookOptions struct {
+	// BatchSize is the maximum number of entries sent in a request, defaults to 1
+	BatchSize int
+	// BatchDelay is how long a batch waits for more entries before it is sent
+	BatchDelay time.Duration
+	// QueueSize is the number of entries queued per endpoint, entries are dropped when the queue is full
+	QueueSize int
+	// MaxRetries is the number of times a failed request is retried
+	MaxRetries int
+	// Backoff is the time waited before the first retry, it doubles with every retry
+	Backoff time.Duration
+	// Client performs the requests, defaults to a client with a 10 second timeout
+	Client *http.Client
+}
+
+// ParseTemplate parses a body template, the "json" function encodes a value as JSON
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("body").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			out, err := json.Marshal(v)
+			return string(out), err
+		},
+	}).Parse(text)
+}
+
+type webhookEndpoint struct {
+	Endpoint
+	types map[string]bool
+	queue chan *WebhookItem
+}
+
+func (e *webhookEndpoint) accepts(name string) bool {
+	return len(e.types) == 0 || e.types[name]
+}
+
+// Webhook is a feed posting entries to HTTP endpoints
+type Webhook struct {
+	endpoints []*webhookEndpoint
+	options   WebhookOptions
+	wg        sync.WaitGroup
+	done      chan struct{}
+
+	mutex  sync.RWMutex
+	closed bool
+	err    error
+
+	dropped uint64
+	failed  uint64
+}
+
+// NewWebhook constructs a webhook feed and starts sending to the endpoints
+func NewWebhook(endpoints []Endpoint, options WebhookOptions) *Webhook {
+	if options.BatchSize < 1 {
+		options.BatchSize = 1
+	}
+	if options.Client == nil {
+		options.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	w := &Webhook{options: options, done: make(chan struct{})}
+	for _, endpoint := range endpoints {
+		e := &webhookEndpoint{
+			Endpoint: endpoint,
+			types:    make(map[string]bool),
+			queue:    make(chan *WebhookItem, options.QueueSize),
+		}
+		if e.ContentType == "" {
+			e.ContentType = "application/json"
+		}
+		for _, t := range endpoint.Types {
+			e.types[t] = true
+		}
+		w.endpoints = append(w.endpoints, e)
+
+		w.wg.Add(1)
+		go w.run(e)
+	}
+	return w
+}
+
+// PushEntry queues the entry for the endpoints accepting its type
+func (w *Webhook) PushEntry(entry *api.FeedEntry) {
+	item := &WebhookItem{
+		Type:      TypeName(entry.Message),
+		Timestamp: entry.Timestamp,
+		Message:   entry.Message,
+		Entry:     entry,
+	}
+	if item.Timestamp.IsZero() {
+		item.Timestamp = time.Now()
+	}
+
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	if w.closed {
+		atomic.AddUint64(&w.dropped, 1)
+		return
+	}
+	for _, e := range w.endpoints {
+		if !e.accepts(item.Type) {
+			continue
+		}
+		select {
+		case e.queue <- item:
+		default:
+			atomic.AddUint64(&w.dropped, 1)
+		}
+	}
+}
+
+// Push queues the entry for the endpoints accepting its type
+func (w *Webhook) Push(entry interface{}) {
+	w.PushEntry(&api.FeedEntry{Message: entry})
+}
+
+// Dropped returns the number of entries dropped because a queue was full or the feed was closed
+func (w *Webhook) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Failed returns the number of batches that could not be sent after retrying
+func (w *Webhook) Failed() uint64 {
+	return atomic.LoadUint64(&w.failed)
+}
+
+// Err returns the last error encountered while sending a batch
+func (w *Webhook) Err() error {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.err
+}
+
+// Close stops accepting entries and waits for the queued entries to be sent, failed batches are not retried
+// once the feed is closed
+func (w *Webhook) Close() error {
+	w.mutex.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.done)
+		for _, e := range w.endpoints {
+			close(e.queue)
+		}
+	}
+	w.mutex.Unlock()
+
+	w.wg.Wait()
+	return w.Err()
+}
+
+// run sends the queued entries of the endpoint in batches until the queue is closed
+func (w *Webhook) run(e *webhookEndpoint) {
+	defer w.wg.Done()
+
+	for item := range e.queue {
+		batch := []*WebhookItem{item}
+		open := w.fill(e, &batch)
+		w.send(e, batch)
+		if !open {
+			return
+		}
+	}
+}
+
+// fill adds queued entries to the batch until it is full or the batch delay has passed, it returns false
+// when the queue is closed
+func (w *Webhook) fill(e *webhookEndpoint, batch *[]*WebhookItem) bool {
+	if len(*batch) >= w.options.BatchSize {
+		return true
+	}
+	timer := time.NewTimer(w.options.BatchDelay)
+	defer timer.Stop()
+	for len(*batch) < w.options.BatchSize {
+		select {
+		case item, ok := <-e.queue:
+			if !ok {
+				return false
+			}
+			*batch = append(*batch, item)
+		case <-timer.C:
+			return true
+		}
+	}
+	return true
+}
+
+func (w *Webhook) render(e *webhookEndpoint, batch []*WebhookItem) ([]byte, error) {
+	if e.Template != nil {
+		var buf bytes.Buffer
+		err := e.Template.Execute(&buf, batch)
+		return buf.Bytes(), err
+	}
+
+	records := make([]*Record, len(batch))
+	for idx, item := range batch {
+		entry := *item.Entry
+		entry.Timestamp = item.Timestamp
+		record, err := NewRecord(&entry)
+		if err != nil {
+			return nil, err
+		}
+		records[idx] = record
+	}
+	return json.Marshal(records)
+}
+
+// post sends the body to the endpoint, it returns true if the request failed and may succeed when retried
+func (w *Webhook) post(e *webhookEndpoint, body []byte) (bool, error) {
+	response, err := w.options.Client.Post(e.URL, e.ContentType, bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	// The body is read to the end so the connection can be reused
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+		return retry, fmt.Errorf("Endpoint %s responded with status %d", e.URL, response.StatusCode)
+	}
+	return false, nil
+}
+
+// wait returns false if the feed is closed before the delay has passed
+func (w *Webhook) wait(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-w.done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// send posts the batch to the endpoint, retrying with backoff when it fails until the feed is closed
+func (w *Webhook) send(e *webhookEndpoint, batch []*WebhookItem) {
+	body, err := w.render(e, batch)
+	if err == nil {
+		backoff := w.options.Backoff
+		for attempt := 0; ; attempt++ {
+			var retry bool
+			retry, err = w.post(e, body)
+			if !retry || attempt >= w.options.MaxRetries || !w.wait(backoff) {
+				break
+			}
+			backoff *= 2
+		}
+	}
+	if err != nil {
+		atomic.AddUint64(&w.failed, 1)
+		w.mutex.Lock()
+		w.err = err
+		w.mutex.Unlock()
+	}
+}
@@ -0,0 +1,130 @@
+package feed
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+)
+
+type webhookServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	bodies   []string
+	failures int
+}
+
+func newWebhookServer(failures int) *webhookServer {
+	s := &webhookServer{failures: failures}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.failures > 0 {
+			s.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		s.bodies = append(s.bodies, string(body))
+	}))
+	return s
+}
+
+// received waits up to a second for the server to receive the number of bodies
+func (s *webhookServer) received(n int) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		s.mutex.Lock()
+		count := len(s.bodies)
+		s.mutex.Unlock()
+		if count >= n {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWebhook(t *testing.T) {
+	pokemons := newWebhookServer(1)
+	defer pokemons.Close()
+	all := newWebhookServer(0)
+	defer all.Close()
+
+	tmpl, err := ParseTemplate(`{{range .}}{{.Message.EncounterId}};{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pokemon := &protos.WildPokemon{}
+	w := NewWebhook([]Endpoint{
+		{URL: pokemons.URL, Types: []string{TypeName(pokemon)}, Template: tmpl},
+		{URL: all.URL},
+	}, WebhookOptions{
+		BatchSize:  2,
+		BatchDelay: time.Second,
+		QueueSize:  10,
+		MaxRetries: 1,
+		Backoff:    time.Millisecond,
+	})
+
+	w.Push(&protos.WildPokemon{EncounterId: 1})
+	w.Push(&protos.GetPlayerResponse{})
+	w.Push(&protos.WildPokemon{EncounterId: 2})
+
+	// Failed batches are not retried once the feed is closed
+	if !pokemons.received(1) {
+		t.Fatal("Expected the Pokémon to be sent after retrying")
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pokemons.bodies) != 1 || pokemons.bodies[0] != "1;2;" {
+		t.Errorf("Expected the Pokémon to be sent in one batch after retrying, got %v", pokemons.bodies)
+	}
+	if len(all.bodies) != 2 {
+		t.Fatalf("Expected all entries to be sent in 2 batches, got %v", all.bodies)
+	}
+	records := make([]*Record, 0)
+	err = json.Unmarshal([]byte(all.bodies[1]), &records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Type != TypeName(pokemon) {
+		t.Errorf("Expected the last batch to hold the last Pokémon, got %v", all.bodies[1])
+	}
+
+	w.Push(&protos.WildPokemon{EncounterId: 3})
+	if w.Dropped() != 1 || w.Failed() != 0 {
+		t.Errorf("Expected 1 dropped entry and no failed batches, got %d and %d", w.Dropped(), w.Failed())
+	}
+}
+
+func TestWebhookCloseDuringBackoff(t *testing.T) {
+	failing := newWebhookServer(10)
+	defer failing.Close()
+
+	w := NewWebhook([]Endpoint{{URL: failing.URL}}, WebhookOptions{
+		QueueSize:  10,
+		MaxRetries: 3,
+		Backoff:    time.Hour,
+	})
+	w.Push(&protos.WildPokemon{EncounterId: 1})
+
+	closed := make(chan error)
+	go func() {
+		closed <- w.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err == nil || w.Failed() != 1 {
+			t.Errorf("Expected the batch to fail, got %v and %d failed batches", err, w.Failed())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Close not to wait for the backoff")
+	}
+}
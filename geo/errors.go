@@ -0,0 +1,9 @@
+package geo
+
+import "errors"
+
+// ErrUnsupportedGeometry happens when a GeoJSON document does not contain the expected type of geometry
+var ErrUnsupportedGeometry = errors.New("The GeoJSON geometry is not supported")
+
+// ErrAntimeridian happens when a polygon crosses the antimeridian, which is not supported
+var ErrAntimeridian = errors.New("Polygons crossing the antimeridian are not supported")
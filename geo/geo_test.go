@@ -0,0 +1,93 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+var locations = []api.Location{
+	{Lat: 59.3293, Lon: 18.0686},
+	{Lat: 59.3326, Lon: 18.0649, Alt: 12},
+	{Lat: 59.3251, Lon: 18.0711},
+}
+
+func assertLocations(t *testing.T, expected, actual []api.Location) {
+	if len(expected) != len(actual) {
+		t.Fatalf("Expected %d locations, got %d", len(expected), len(actual))
+	}
+	for idx := range expected {
+		if expected[idx] != actual[idx] {
+			t.Errorf("Expected location %v, got %v", expected[idx], actual[idx])
+		}
+	}
+}
+
+func TestGeoJSONRoundTrip(t *testing.T) {
+	out, err := json.Marshal(FromLocations(locations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseLocations(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLocations(t, locations, parsed)
+
+	out, err = json.Marshal(LineString(locations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err = ParseLocations(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLocations(t, locations, parsed)
+}
+
+func TestParsePolygon(t *testing.T) {
+	fc := NewFeatureCollection()
+	fc.Add(Point(locations[0]), nil)
+	fc.Add(Polygon([][]api.Location{locations}), nil)
+	out, err := json.Marshal(fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rings, err := ParsePolygon(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rings) != 1 {
+		t.Fatalf("Expected one ring, got %d", len(rings))
+	}
+	assertLocations(t, append(locations, locations[0]), rings[0])
+
+	_, err = ParsePolygon([]byte(`{"type": "Point", "coordinates": [0, 0]}`))
+	if err != ErrUnsupportedGeometry {
+		t.Errorf("Expected unsupported geometry, got %v", err)
+	}
+
+	_, err = ParsePolygon([]byte(`{"type": "Polygon", "coordinates": [[[179, 0], [-179, 0], [-179, 1], [179, 1], [179, 0]]]}`))
+	if err != ErrAntimeridian {
+		t.Errorf("Expected a polygon crossing the antimeridian to be rejected, got %v", err)
+	}
+}
+
+func TestGPXRoundTrip(t *testing.T) {
+	g := NewGPX()
+	g.AddRoute("route", locations)
+
+	var out bytes.Buffer
+	err := g.Encode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseGPXLocations(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLocations(t, locations, parsed)
+}
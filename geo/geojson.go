@@ -0,0 +1,274 @@
+package geo
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/golang/geo/s2"
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+const (
+	typeFeatureCollection = "FeatureCollection"
+	typeFeature           = "Feature"
+	typePoint             = "Point"
+	typeMultiPoint        = "MultiPoint"
+	typeLineString        = "LineString"
+	typePolygon           = "Polygon"
+	typeMultiPolygon      = "MultiPolygon"
+)
+
+// Geometry is a GeoJSON geometry object
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Feature is a GeoJSON feature object
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// FeatureCollection is a GeoJSON feature collection object
+type FeatureCollection struct {
+	Type     string     `json:"type"`
+	Features []*Feature `json:"features"`
+}
+
+// NewFeatureCollection constructs an empty GeoJSON feature collection
+func NewFeatureCollection() *FeatureCollection {
+	return &FeatureCollection{
+		Type:     typeFeatureCollection,
+		Features: make([]*Feature, 0),
+	}
+}
+
+// Add puts a feature with the geometry and properties in the collection
+func (fc *FeatureCollection) Add(geometry *Geometry, properties map[string]interface{}) {
+	if properties == nil {
+		properties = make(map[string]interface{})
+	}
+	fc.Features = append(fc.Features, &Feature{
+		Type:       typeFeature,
+		Geometry:   geometry,
+		Properties: properties,
+	})
+}
+
+func position(l api.Location) []float64 {
+	if l.Alt != 0 {
+		return []float64{l.Lon, l.Lat, l.Alt}
+	}
+	return []float64{l.Lon, l.Lat}
+}
+
+func positions(locations []api.Location) [][]float64 {
+	coordinates := make([][]float64, len(locations))
+	for idx, l := range locations {
+		coordinates[idx] = position(l)
+	}
+	return coordinates
+}
+
+func newGeometry(geometryType string, coordinates interface{}) *Geometry {
+	raw, _ := json.Marshal(coordinates)
+	return &Geometry{
+		Type:        geometryType,
+		Coordinates: raw,
+	}
+}
+
+// Point returns a GeoJSON point geometry of the location
+func Point(l api.Location) *Geometry {
+	return newGeometry(typePoint, position(l))
+}
+
+// LineString returns a GeoJSON line string geometry through the locations
+func LineString(locations []api.Location) *Geometry {
+	return newGeometry(typeLineString, positions(locations))
+}
+
+// Polygon returns a GeoJSON polygon geometry, the first ring is the exterior and the rest are holes
+func Polygon(rings [][]api.Location) *Geometry {
+	coordinates := make([][][]float64, len(rings))
+	for idx, ring := range rings {
+		coordinates[idx] = positions(closeRing(ring))
+	}
+	return newGeometry(typePolygon, coordinates)
+}
+
+func closeRing(ring []api.Location) []api.Location {
+	if len(ring) > 0 && (ring[0].Lat != ring[len(ring)-1].Lat || ring[0].Lon != ring[len(ring)-1].Lon) {
+		return append(ring[:len(ring):len(ring)], ring[0])
+	}
+	return ring
+}
+
+func toLocation(p []float64) api.Location {
+	l := api.Location{}
+	if len(p) > 1 {
+		l.Lon = p[0]
+		l.Lat = p[1]
+	}
+	if len(p) > 2 {
+		l.Alt = p[2]
+	}
+	return l
+}
+
+func toLocations(ps [][]float64) []api.Location {
+	locations := make([]api.Location, len(ps))
+	for idx, p := range ps {
+		locations[idx] = toLocation(p)
+	}
+	return locations
+}
+
+// geometries returns all geometries of a GeoJSON document being either a geometry, feature or feature collection
+func geometries(data []byte) ([]*Geometry, error) {
+	var object struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+		Geometry    *Geometry       `json:"geometry"`
+		Features    []*Feature      `json:"features"`
+	}
+	err := json.Unmarshal(data, &object)
+	if err != nil {
+		return nil, err
+	}
+
+	switch object.Type {
+	case typeFeatureCollection:
+		result := make([]*Geometry, 0, len(object.Features))
+		for _, feature := range object.Features {
+			if feature.Geometry != nil {
+				result = append(result, feature.Geometry)
+			}
+		}
+		return result, nil
+	case typeFeature:
+		if object.Geometry == nil {
+			return nil, ErrUnsupportedGeometry
+		}
+		return []*Geometry{object.Geometry}, nil
+	default:
+		return []*Geometry{{Type: object.Type, Coordinates: object.Coordinates}}, nil
+	}
+}
+
+// ParsePolygon returns the rings of the first polygon in a GeoJSON document, the first ring is the exterior and the rest are holes,
+// polygons crossing the antimeridian are rejected with ErrAntimeridian
+func ParsePolygon(data []byte) ([][]api.Location, error) {
+	found, err := geometries(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, geometry := range found {
+		switch geometry.Type {
+		case typePolygon:
+			var coordinates [][][]float64
+			err = json.Unmarshal(geometry.Coordinates, &coordinates)
+			if err != nil {
+				return nil, err
+			}
+			return toRings(coordinates)
+		case typeMultiPolygon:
+			var coordinates [][][][]float64
+			err = json.Unmarshal(geometry.Coordinates, &coordinates)
+			if err != nil {
+				return nil, err
+			}
+			if len(coordinates) > 0 {
+				return toRings(coordinates[0])
+			}
+		}
+	}
+
+	return nil, ErrUnsupportedGeometry
+}
+
+// FromLocations returns a feature collection with a point for each location
+func FromLocations(locations []api.Location) *FeatureCollection {
+	fc := NewFeatureCollection()
+	for _, l := range locations {
+		fc.Add(Point(l), nil)
+	}
+	return fc
+}
+
+// FromRoute returns a feature collection with a line string through the locations and a point for each stop
+func FromRoute(locations []api.Location) *FeatureCollection {
+	fc := NewFeatureCollection()
+	fc.Add(LineString(locations), map[string]interface{}{"type": "route"})
+	for idx, l := range locations {
+		fc.Add(Point(l), map[string]interface{}{"type": "stop", "stop": idx})
+	}
+	return fc
+}
+
+// ParseLocations returns the locations of all points, multi points and line strings in a GeoJSON document
+func ParseLocations(data []byte) ([]api.Location, error) {
+	found, err := geometries(data)
+	if err != nil {
+		return nil, err
+	}
+
+	locations := make([]api.Location, 0)
+	for _, geometry := range found {
+		switch geometry.Type {
+		case typePoint:
+			var coordinates []float64
+			err = json.Unmarshal(geometry.Coordinates, &coordinates)
+			if err != nil {
+				return nil, err
+			}
+			locations = append(locations, toLocation(coordinates))
+		case typeMultiPoint, typeLineString:
+			var coordinates [][]float64
+			err = json.Unmarshal(geometry.Coordinates, &coordinates)
+			if err != nil {
+				return nil, err
+			}
+			locations = append(locations, toLocations(coordinates)...)
+		}
+	}
+
+	if len(locations) == 0 {
+		return nil, ErrUnsupportedGeometry
+	}
+	return locations, nil
+}
+
+func toRings(coordinates [][][]float64) ([][]api.Location, error) {
+	rings := make([][]api.Location, len(coordinates))
+	for idx, ring := range coordinates {
+		rings[idx] = toLocations(ring)
+		// An edge spanning more than half the globe is taken to cross the antimeridian
+		for i := range rings[idx] {
+			a, b := rings[idx][i], rings[idx][(i+1)%len(rings[idx])]
+			if math.Abs(b.Lon-a.Lon) > 180 {
+				return nil, ErrAntimeridian
+			}
+		}
+	}
+	return rings, nil
+}
+
+// CellVertices returns the four corners of an S2 cell in counter-clockwise order
+func CellVertices(cellID uint64) []api.Location {
+	cell := s2.CellFromCellID(s2.CellID(cellID))
+	vertices := make([]api.Location, 4)
+	for k := range vertices {
+		vertex := s2.LatLngFromPoint(cell.Vertex(k))
+		vertices[k] = api.Location{Lat: vertex.Lat.Degrees(), Lon: vertex.Lng.Degrees()}
+	}
+	return vertices
+}
+
+// Cell returns a GeoJSON polygon geometry of an S2 cell
+func Cell(cellID uint64) *Geometry {
+	return Polygon([][]api.Location{CellVertices(cellID)})
+}
@@ -0,0 +1,149 @@
+package geo
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+const gpxNamespace = "http://www.topografix.com/GPX/1/1"
+const gpxCreator = "pgoapi-go"
+
+// GPX is the root element of a GPX document
+type GPX struct {
+	XMLName   xml.Name      `xml:"gpx"`
+	Xmlns     string        `xml:"xmlns,attr"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Waypoints []GPXWaypoint `xml:"wpt"`
+	Routes    []GPXRoute    `xml:"rte"`
+	Tracks    []GPXTrack    `xml:"trk"`
+}
+
+// GPXWaypoint is a point of interest, or a point of a route or track
+type GPXWaypoint struct {
+	Lat         float64 `xml:"lat,attr"`
+	Lon         float64 `xml:"lon,attr"`
+	Ele         float64 `xml:"ele,omitempty"`
+	Name        string  `xml:"name,omitempty"`
+	Description string  `xml:"desc,omitempty"`
+	Type        string  `xml:"type,omitempty"`
+}
+
+// GPXRoute is an ordered list of points leading to a destination
+type GPXRoute struct {
+	Name   string        `xml:"name,omitempty"`
+	Points []GPXWaypoint `xml:"rtept"`
+}
+
+// GPXTrack is an ordered list of points describing a path
+type GPXTrack struct {
+	Name     string            `xml:"name,omitempty"`
+	Segments []GPXTrackSegment `xml:"trkseg"`
+}
+
+// GPXTrackSegment is a continuous span of track points
+type GPXTrackSegment struct {
+	Points []GPXWaypoint `xml:"trkpt"`
+}
+
+// NewGPX constructs an empty GPX document
+func NewGPX() *GPX {
+	return &GPX{
+		Xmlns:   gpxNamespace,
+		Version: "1.1",
+		Creator: gpxCreator,
+	}
+}
+
+// NewGPXWaypoint returns a waypoint at the location
+func NewGPXWaypoint(l api.Location, name string) GPXWaypoint {
+	return GPXWaypoint{
+		Lat:  l.Lat,
+		Lon:  l.Lon,
+		Ele:  l.Alt,
+		Name: name,
+	}
+}
+
+// AddWaypoint puts a named waypoint at the location in the document
+func (g *GPX) AddWaypoint(l api.Location, name string) {
+	g.Waypoints = append(g.Waypoints, NewGPXWaypoint(l, name))
+}
+
+// AddRoute puts a route through the locations in the document
+func (g *GPX) AddRoute(name string, locations []api.Location) {
+	route := GPXRoute{
+		Name:   name,
+		Points: make([]GPXWaypoint, len(locations)),
+	}
+	for idx, l := range locations {
+		route.Points[idx] = NewGPXWaypoint(l, "")
+	}
+	g.Routes = append(g.Routes, route)
+}
+
+// AddTrack puts a track through the locations in the document
+func (g *GPX) AddTrack(name string, locations []api.Location) {
+	segment := GPXTrackSegment{
+		Points: make([]GPXWaypoint, len(locations)),
+	}
+	for idx, l := range locations {
+		segment.Points[idx] = NewGPXWaypoint(l, "")
+	}
+	g.Tracks = append(g.Tracks, GPXTrack{
+		Name:     name,
+		Segments: []GPXTrackSegment{segment},
+	})
+}
+
+// Locations returns the locations of all waypoints, route points and track points in the document
+func (g *GPX) Locations() []api.Location {
+	locations := make([]api.Location, 0)
+	add := func(points []GPXWaypoint) {
+		for _, p := range points {
+			locations = append(locations, api.Location{Lat: p.Lat, Lon: p.Lon, Alt: p.Ele})
+		}
+	}
+	add(g.Waypoints)
+	for _, route := range g.Routes {
+		add(route.Points)
+	}
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			add(segment.Points)
+		}
+	}
+	return locations
+}
+
+// ParseGPX reads a GPX document
+func ParseGPX(data []byte) (*GPX, error) {
+	g := &GPX{}
+	err := xml.Unmarshal(data, g)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// ParseGPXLocations returns the locations of all waypoints, route points and track points in a GPX document
+func ParseGPXLocations(data []byte) ([]api.Location, error) {
+	g, err := ParseGPX(data)
+	if err != nil {
+		return nil, err
+	}
+	return g.Locations(), nil
+}
+
+// Encode writes the document as XML
+func (g *GPX) Encode(w io.Writer) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(g)
+}
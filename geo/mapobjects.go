@@ -0,0 +1,124 @@
+package geo
+
+import (
+	"strconv"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+func formatID(id uint64) string {
+	return strconv.FormatUint(id, 10)
+}
+
+func wildPokemonProperties(cellID uint64, pokemon *protos.WildPokemon) map[string]interface{} {
+	return map[string]interface{}{
+		"type":                       "wild_pokemon",
+		"cell_id":                    formatID(cellID),
+		"encounter_id":               formatID(pokemon.EncounterId),
+		"pokemon_id":                 pokemon.GetPokemonData().GetPokemonId().String(),
+		"spawn_point_id":             pokemon.SpawnPointId,
+		"last_modified_timestamp_ms": pokemon.LastModifiedTimestampMs,
+		"time_till_hidden_ms":        pokemon.TimeTillHiddenMs,
+	}
+}
+
+func catchablePokemonProperties(cellID uint64, pokemon *protos.MapPokemon) map[string]interface{} {
+	return map[string]interface{}{
+		"type":                    "catchable_pokemon",
+		"cell_id":                 formatID(cellID),
+		"encounter_id":            formatID(pokemon.EncounterId),
+		"pokemon_id":              pokemon.PokemonId.String(),
+		"spawn_point_id":          pokemon.SpawnPointId,
+		"expiration_timestamp_ms": pokemon.ExpirationTimestampMs,
+	}
+}
+
+func fortProperties(cellID uint64, fort *protos.FortData) map[string]interface{} {
+	properties := map[string]interface{}{
+		"type":                       "fort",
+		"cell_id":                    formatID(cellID),
+		"fort_id":                    fort.Id,
+		"fort_type":                  fort.Type.String(),
+		"enabled":                    fort.Enabled,
+		"last_modified_timestamp_ms": fort.LastModifiedTimestampMs,
+	}
+	if fort.Type == protos.FortType_GYM {
+		properties["owned_by_team"] = fort.OwnedByTeam.String()
+		properties["guard_pokemon_id"] = fort.GuardPokemonId.String()
+		properties["guard_pokemon_cp"] = fort.GuardPokemonCp
+		properties["gym_points"] = fort.GymPoints
+		properties["is_in_battle"] = fort.IsInBattle
+	} else {
+		properties["cooldown_complete_timestamp_ms"] = fort.CooldownCompleteTimestampMs
+	}
+	if lure := fort.GetLureInfo(); lure != nil {
+		properties["lure_encounter_id"] = formatID(lure.EncounterId)
+		properties["lure_pokemon_id"] = lure.ActivePokemonId.String()
+		properties["lure_expires_timestamp_ms"] = lure.LureExpiresTimestampMs
+	}
+	return properties
+}
+
+func spawnPointProperties(cellID uint64, decimated bool) map[string]interface{} {
+	return map[string]interface{}{
+		"type":      "spawn_point",
+		"cell_id":   formatID(cellID),
+		"decimated": decimated,
+	}
+}
+
+// FromMapObjects returns a feature collection with a point for each wild and catchable Pokémon, fort and spawn point in the map cells
+func FromMapObjects(mapObjects *protos.GetMapObjectsResponse) *FeatureCollection {
+	fc := NewFeatureCollection()
+	for _, cell := range mapObjects.GetMapCells() {
+		for _, pokemon := range cell.WildPokemons {
+			l := api.Location{Lat: pokemon.Latitude, Lon: pokemon.Longitude}
+			fc.Add(Point(l), wildPokemonProperties(cell.S2CellId, pokemon))
+		}
+		for _, pokemon := range cell.CatchablePokemons {
+			l := api.Location{Lat: pokemon.Latitude, Lon: pokemon.Longitude}
+			fc.Add(Point(l), catchablePokemonProperties(cell.S2CellId, pokemon))
+		}
+		for _, fort := range cell.Forts {
+			l := api.Location{Lat: fort.Latitude, Lon: fort.Longitude}
+			fc.Add(Point(l), fortProperties(cell.S2CellId, fort))
+		}
+		for _, spawnPoint := range cell.SpawnPoints {
+			l := api.Location{Lat: spawnPoint.Latitude, Lon: spawnPoint.Longitude}
+			fc.Add(Point(l), spawnPointProperties(cell.S2CellId, false))
+		}
+		for _, spawnPoint := range cell.DecimatedSpawnPoints {
+			l := api.Location{Lat: spawnPoint.Latitude, Lon: spawnPoint.Longitude}
+			fc.Add(Point(l), spawnPointProperties(cell.S2CellId, true))
+		}
+	}
+	return fc
+}
+
+// GPXFromMapObjects returns a GPX document with a waypoint for each wild Pokémon, fort and spawn point in the map cells
+func GPXFromMapObjects(mapObjects *protos.GetMapObjectsResponse) *GPX {
+	g := NewGPX()
+	for _, cell := range mapObjects.GetMapCells() {
+		for _, pokemon := range cell.WildPokemons {
+			l := api.Location{Lat: pokemon.Latitude, Lon: pokemon.Longitude}
+			waypoint := NewGPXWaypoint(l, pokemon.GetPokemonData().GetPokemonId().String())
+			waypoint.Description = formatID(pokemon.EncounterId)
+			waypoint.Type = "wild_pokemon"
+			g.Waypoints = append(g.Waypoints, waypoint)
+		}
+		for _, fort := range cell.Forts {
+			l := api.Location{Lat: fort.Latitude, Lon: fort.Longitude}
+			waypoint := NewGPXWaypoint(l, fort.Id)
+			waypoint.Type = fort.Type.String()
+			g.Waypoints = append(g.Waypoints, waypoint)
+		}
+		for _, spawnPoint := range cell.SpawnPoints {
+			l := api.Location{Lat: spawnPoint.Latitude, Lon: spawnPoint.Longitude}
+			waypoint := NewGPXWaypoint(l, "")
+			waypoint.Type = "spawn_point"
+			g.Waypoints = append(g.Waypoints, waypoint)
+		}
+	}
+	return g
+}
@@ -0,0 +1,384 @@
+// Package history records sightings and fort states in an embedded key-value store and queries them
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+var (
+	sightingsBucket  = []byte("sightings")
+	fortsBucket      = []byte("forts")
+	encountersBucket = []byte("encounters")
+	fortStatesBucket = []byte("fort_states")
+)
+
+// Sighting is a wild Pokémon as it was first seen
+type Sighting struct {
+	EncounterID  uint64           `json:"encounter_id"`
+	PokemonID    protos.PokemonId `json:"pokemon_id"`
+	SpawnPointID string           `json:"spawn_point_id"`
+	CellID       uint64           `json:"cell_id"`
+	Lat          float64          `json:"lat"`
+	Lon          float64          `json:"lon"`
+	SeenAt       time.Time        `json:"seen_at"`
+	DespawnTime  time.Time        `json:"despawn_time,omitempty"`
+}
+
+// FortState is the state of a fort from the time it was seen until it changed
+type FortState struct {
+	FortID         string           `json:"fort_id"`
+	Type           protos.FortType  `json:"type"`
+	Lat            float64          `json:"lat"`
+	Lon            float64          `json:"lon"`
+	Team           protos.TeamColor `json:"team"`
+	GymPoints      int64            `json:"gym_points"`
+	GuardPokemonID protos.PokemonId `json:"guard_pokemon_id"`
+	LurePokemonID  protos.PokemonId `json:"lure_pokemon_id,omitempty"`
+	LureExpires    time.Time        `json:"lure_expires,omitempty"`
+	SeenAt         time.Time        `json:"seen_at"`
+}
+
+// changed tells if the fort is in a different state than the other state
+func (f *FortState) changed(other *FortState) bool {
+	a, b := *f, *other
+	a.SeenAt, b.SeenAt = time.Time{}, time.Time{}
+	// Times read back from the store have a different location than new times, so they are compared with Equal
+	a.LureExpires, b.LureExpires = time.Time{}, time.Time{}
+	return a != b || !f.LureExpires.Equal(other.LureExpires)
+}
+
+// Query selects the records seen within a time window, of species and within a bounding box.
+// Zero values leave the records unfiltered.
+type Query struct {
+	From    time.Time
+	To      time.Time
+	Species []protos.PokemonId
+	Box     *api.BoundingBox
+	Limit   int
+}
+
+func (q *Query) contains(lat, lon float64) bool {
+	return q.Box == nil || q.Box.Contains(&api.Location{Lat: lat, Lon: lon})
+}
+
+func (q *Query) matchesSpecies(species protos.PokemonId) bool {
+	if len(q.Species) == 0 {
+		return true
+	}
+	for _, s := range q.Species {
+		if s == species {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns a time ordered key of the timestamp followed by the identifier
+func key(t time.Time, id []byte) []byte {
+	k := make([]byte, 8+len(id))
+	binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	copy(k[8:], id)
+	return k
+}
+
+func timeKey(t time.Time) []byte {
+	return key(t, nil)
+}
+
+func keyTime(k []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(k[:8])))
+}
+
+func encounterKey(encounterID uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, encounterID)
+	return k
+}
+
+// Store is a feed recording the wild Pokémon and forts of map objects responses in a bolt database
+type Store struct {
+	db  *bolt.DB
+	now func() time.Time
+
+	mutex sync.Mutex
+	err   error
+}
+
+// Open opens or creates the store at the path. A store is locked while it is open for writing, so it cannot be
+// opened by another process until it is closed.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{sightingsBucket, fortsBucket, encountersBucket, fortStatesBucket} {
+			_, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db, now: time.Now}, nil
+}
+
+// OpenReadOnly opens the existing store at the path for querying, it cannot be opened while a process is writing to it
+func OpenReadOnly(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db, now: time.Now}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) putSighting(tx *bolt.Tx, sighting *Sighting) error {
+	encounters := tx.Bucket(encountersBucket)
+	id := encounterKey(sighting.EncounterID)
+	if encounters.Get(id) != nil {
+		return nil
+	}
+	value, err := json.Marshal(sighting)
+	if err != nil {
+		return err
+	}
+	k := key(sighting.SeenAt, id)
+	err = tx.Bucket(sightingsBucket).Put(k, value)
+	if err != nil {
+		return err
+	}
+	return encounters.Put(id, k)
+}
+
+func (s *Store) putFort(tx *bolt.Tx, state *FortState) error {
+	states := tx.Bucket(fortStatesBucket)
+	id := []byte(state.FortID)
+	if previous := states.Get(id); previous != nil {
+		last := &FortState{}
+		err := json.Unmarshal(previous, last)
+		if err != nil {
+			return err
+		}
+		if !state.changed(last) {
+			return nil
+		}
+	}
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	err = tx.Bucket(fortsBucket).Put(key(state.SeenAt, id), value)
+	if err != nil {
+		return err
+	}
+	return states.Put(id, value)
+}
+
+// Record stores the wild Pokémon not seen before and the forts that changed state
+func (s *Store) Record(snapshot *api.MapSnapshot) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, pokemon := range snapshot.WildPokemons {
+			err := s.putSighting(tx, &Sighting{
+				EncounterID:  pokemon.Pokemon.EncounterId,
+				PokemonID:    pokemon.Pokemon.GetPokemonData().GetPokemonId(),
+				SpawnPointID: pokemon.Pokemon.SpawnPointId,
+				CellID:       pokemon.CellID,
+				Lat:          pokemon.Pokemon.Latitude,
+				Lon:          pokemon.Pokemon.Longitude,
+				SeenAt:       snapshot.Timestamp,
+				DespawnTime:  pokemon.DespawnTime,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for _, f := range snapshot.Forts {
+			state := &FortState{
+				FortID:         f.Fort.Id,
+				Type:           f.Fort.Type,
+				Lat:            f.Fort.Latitude,
+				Lon:            f.Fort.Longitude,
+				Team:           f.Fort.OwnedByTeam,
+				GymPoints:      f.Fort.GymPoints,
+				GuardPokemonID: f.Fort.GuardPokemonId,
+				SeenAt:         snapshot.Timestamp,
+			}
+			if lure := f.Fort.LureInfo; lure != nil {
+				state.LurePokemonID = lure.ActivePokemonId
+				state.LureExpires = time.Unix(0, lure.LureExpiresTimestampMs*int64(time.Millisecond))
+			}
+			err := s.putFort(tx, state)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PushEntry records the map objects or wild Pokémon of the entry, other entries are ignored
+func (s *Store) PushEntry(entry *api.FeedEntry) {
+	timestamp := entry.Timestamp
+	if timestamp.IsZero() {
+		timestamp = s.now()
+	}
+
+	var mapObjects *protos.GetMapObjectsResponse
+	switch m := entry.Message.(type) {
+	case *protos.GetMapObjectsResponse:
+		mapObjects = m
+	case *protos.WildPokemon:
+		mapObjects = &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{WildPokemons: []*protos.WildPokemon{m}}}}
+	default:
+		return
+	}
+
+	location := entry.Location
+	err := s.Record(api.NewMapSnapshot(mapObjects, &location, timestamp))
+	if err != nil {
+		s.mutex.Lock()
+		s.err = err
+		s.mutex.Unlock()
+	}
+}
+
+// Push records the map objects or wild Pokémon with the current time
+func (s *Store) Push(entry interface{}) {
+	s.PushEntry(&api.FeedEntry{Message: entry})
+}
+
+// Err returns the last error encountered while recording entries
+func (s *Store) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
+
+// scan calls the function with the values of the bucket in time order from the start of the query
+// until it returns false or the end of the query is reached
+func (s *Store) scan(bucket []byte, q *Query, fn func(value []byte) (bool, error)) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucket).Cursor()
+		k, v := c.First()
+		if !q.From.IsZero() {
+			k, v = c.Seek(timeKey(q.From))
+		}
+		for ; k != nil; k, v = c.Next() {
+			if !q.To.IsZero() && !keyTime(k).Before(q.To) {
+				return nil
+			}
+			more, err := fn(v)
+			if err != nil || !more {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Sightings returns the sightings matching the query in the order they were seen
+func (s *Store) Sightings(q Query) ([]*Sighting, error) {
+	sightings := make([]*Sighting, 0)
+	err := s.scan(sightingsBucket, &q, func(value []byte) (bool, error) {
+		sighting := &Sighting{}
+		err := json.Unmarshal(value, sighting)
+		if err != nil {
+			return false, err
+		}
+		if q.matchesSpecies(sighting.PokemonID) && q.contains(sighting.Lat, sighting.Lon) {
+			sightings = append(sightings, sighting)
+		}
+		return q.Limit <= 0 || len(sightings) < q.Limit, nil
+	})
+	return sightings, err
+}
+
+// Forts returns the fort states matching the query in the order they were seen, the species are
+// matched against the guard and lure Pokémon
+func (s *Store) Forts(q Query) ([]*FortState, error) {
+	forts := make([]*FortState, 0)
+	err := s.scan(fortsBucket, &q, func(value []byte) (bool, error) {
+		state := &FortState{}
+		err := json.Unmarshal(value, state)
+		if err != nil {
+			return false, err
+		}
+		species := q.matchesSpecies(state.GuardPokemonID) || q.matchesSpecies(state.LurePokemonID)
+		if species && q.contains(state.Lat, state.Lon) {
+			forts = append(forts, state)
+		}
+		return q.Limit <= 0 || len(forts) < q.Limit, nil
+	})
+	return forts, err
+}
+
+// Prune deletes the records seen before the time and returns how many were deleted. The latest state
+// of each fort is kept so changes are still detected.
+func (s *Store) Prune(before time.Time) (int, error) {
+	deleted := 0
+	end := timeKey(before)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		encounters := tx.Bucket(encountersBucket)
+		for _, bucket := range [][]byte{sightingsBucket, fortsBucket} {
+			c := tx.Bucket(bucket).Cursor()
+			for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0; k, _ = c.First() {
+				if bytes.Equal(bucket, sightingsBucket) {
+					err := encounters.Delete(k[8:])
+					if err != nil {
+						return err
+					}
+				}
+				err := c.Delete()
+				if err != nil {
+					return err
+				}
+				deleted++
+			}
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// Compact writes a copy of the store without the space freed by pruning to the path
+func (s *Store) Compact(path string) error {
+	dst, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	return s.db.View(func(src *bolt.Tx) error {
+		return src.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return dst.Update(func(tx *bolt.Tx) error {
+				bucket, err := tx.CreateBucketIfNotExists(name)
+				if err != nil {
+					return err
+				}
+				// Keys are appended in order, so pages can be filled completely
+				bucket.FillPercent = 1
+				return b.ForEach(func(k, v []byte) error {
+					return bucket.Put(k, v)
+				})
+			})
+		})
+	})
+}
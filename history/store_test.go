@@ -0,0 +1,129 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := Open(filepath.Join(dir, "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	pidgey := &protos.WildPokemon{EncounterId: 1, Latitude: 1, Longitude: 1, PokemonData: &protos.PokemonData{PokemonId: protos.PokemonId_PIDGEY}}
+	rattata := &protos.WildPokemon{EncounterId: 2, Latitude: 2, Longitude: 2, PokemonData: &protos.PokemonData{PokemonId: protos.PokemonId_RATTATA}}
+	gym := &protos.FortData{Id: "gym", Type: protos.FortType_GYM, OwnedByTeam: protos.TeamColor_BLUE}
+	scan := func(timestamp int64, pokemons []*protos.WildPokemon, forts ...*protos.FortData) {
+		store.PushEntry(&api.FeedEntry{
+			Message:   &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{WildPokemons: pokemons, Forts: forts}}},
+			Timestamp: time.Unix(timestamp, 0),
+		})
+	}
+
+	scan(100, []*protos.WildPokemon{pidgey}, gym)
+	scan(200, []*protos.WildPokemon{pidgey, rattata}, gym)
+	scan(300, nil, &protos.FortData{Id: "gym", Type: protos.FortType_GYM, OwnedByTeam: protos.TeamColor_RED})
+	if store.Err() != nil {
+		t.Fatal(store.Err())
+	}
+
+	sightings, err := store.Sightings(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sightings) != 2 || sightings[0].EncounterID != 1 || !sightings[1].SeenAt.Equal(time.Unix(200, 0)) {
+		t.Errorf("Expected each encounter to be recorded once in time order, got %v", sightings)
+	}
+
+	sightings, _ = store.Sightings(Query{Species: []protos.PokemonId{protos.PokemonId_RATTATA}})
+	if len(sightings) != 1 || sightings[0].EncounterID != 2 {
+		t.Errorf("Expected only the Rattata, got %v", sightings)
+	}
+	sightings, _ = store.Sightings(Query{Box: &api.BoundingBox{MinLat: 0.5, MinLon: 0.5, MaxLat: 1.5, MaxLon: 1.5}})
+	if len(sightings) != 1 || sightings[0].EncounterID != 1 {
+		t.Errorf("Expected only the Pidgey within the bounding box, got %v", sightings)
+	}
+	sightings, _ = store.Sightings(Query{From: time.Unix(150, 0), To: time.Unix(250, 0)})
+	if len(sightings) != 1 || sightings[0].EncounterID != 2 {
+		t.Errorf("Expected only the Rattata within the time window, got %v", sightings)
+	}
+
+	forts, err := store.Forts(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forts) != 2 || forts[1].Team != protos.TeamColor_RED {
+		t.Errorf("Expected a state for each change of the gym, got %v", forts)
+	}
+
+	deleted, err := store.Prune(time.Unix(250, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 3 {
+		t.Errorf("Expected the sightings and the first gym state to be pruned, got %d", deleted)
+	}
+
+	compacted := filepath.Join(dir, "compacted.db")
+	err = store.Compact(compacted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := OpenReadOnly(compacted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+	if forts, _ = copied.Forts(Query{}); len(forts) != 1 {
+		t.Errorf("Expected the remaining gym state to be copied, got %v", forts)
+	}
+}
+
+func TestStoreLuredFort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := Open(filepath.Join(dir, "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	lured := &protos.FortData{Id: "pokestop", Type: protos.FortType_CHECKPOINT,
+		LureInfo: &protos.FortLureInfo{FortId: "pokestop", ActivePokemonId: protos.PokemonId_PIDGEY, LureExpiresTimestampMs: 1800000}}
+	for _, timestamp := range []int64{1000, 1100} {
+		store.PushEntry(&api.FeedEntry{
+			Message:   &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{Forts: []*protos.FortData{lured}}}},
+			Timestamp: time.Unix(timestamp, 0),
+		})
+	}
+	if store.Err() != nil {
+		t.Fatal(store.Err())
+	}
+
+	forts, err := store.Forts(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forts) != 1 || !forts[0].LureExpires.Equal(time.Unix(1800, 0)) {
+		t.Errorf("Expected the lured fort scanned twice to have one state, got %v", forts)
+	}
+}
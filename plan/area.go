@@ -0,0 +1,147 @@
+package plan
+
+import (
+	"math"
+
+	"github.com/golang/geo/s1"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+// Area is a region to be covered by scan locations
+type Area interface {
+	// Bounds returns the bounding box of the area
+	Bounds() *api.BoundingBox
+	// DistanceTo returns distance in meters from the location to the closest point of the area, zero when inside
+	DistanceTo(l *api.Location) float64
+}
+
+// Circle is an area within a radius in meters from a center location
+type Circle struct {
+	Center api.Location
+	Radius float64
+}
+
+// Bounds returns the bounding box of the circle
+func (c *Circle) Bounds() *api.BoundingBox {
+	return c.Center.BoundingBox(c.Radius)
+}
+
+// DistanceTo returns distance in meters from the location to the circle
+func (c *Circle) DistanceTo(l *api.Location) float64 {
+	return math.Max(0, c.Center.DistanceToLocation(l)-c.Radius)
+}
+
+// Polygon is an area enclosed by rings of locations, the first ring is the exterior and the rest are holes,
+// the rings must not cross the antimeridian
+type Polygon struct {
+	Rings [][]api.Location
+}
+
+// Bounds returns the bounding box of the polygon exterior
+func (p *Polygon) Bounds() *api.BoundingBox {
+	box := &api.BoundingBox{
+		MinLat: math.Inf(1),
+		MinLon: math.Inf(1),
+		MaxLat: math.Inf(-1),
+		MaxLon: math.Inf(-1),
+	}
+	if len(p.Rings) == 0 {
+		return box
+	}
+	for _, l := range p.Rings[0] {
+		box.MinLat = math.Min(box.MinLat, l.Lat)
+		box.MinLon = math.Min(box.MinLon, l.Lon)
+		box.MaxLat = math.Max(box.MaxLat, l.Lat)
+		box.MaxLon = math.Max(box.MaxLon, l.Lon)
+	}
+	return box
+}
+
+// Contains tells if the location is inside the exterior ring and outside all holes
+func (p *Polygon) Contains(l *api.Location) bool {
+	if len(p.Rings) == 0 || !ringContains(p.Rings[0], l) {
+		return false
+	}
+	for _, hole := range p.Rings[1:] {
+		if ringContains(hole, l) {
+			return false
+		}
+	}
+	return true
+}
+
+// DistanceTo returns distance in meters from the location to the polygon
+func (p *Polygon) DistanceTo(l *api.Location) float64 {
+	if p.Contains(l) {
+		return 0
+	}
+
+	// Distances to the edges are measured on a plane centered at the location
+	proj := newProjection(l)
+	distance := math.Inf(1)
+	for _, ring := range p.Rings {
+		for idx := range ring {
+			ax, ay := proj.toPlane(&ring[idx])
+			bx, by := proj.toPlane(&ring[(idx+1)%len(ring)])
+			distance = math.Min(distance, segmentDistance(0, 0, ax, ay, bx, by))
+		}
+	}
+	return distance
+}
+
+// ringContains uses ray casting to tell if the location is inside the ring
+func ringContains(ring []api.Location, l *api.Location) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > l.Lat) != (b.Lat > l.Lat) && l.Lon < (b.Lon-a.Lon)*(l.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// segmentDistance returns distance from the point p to the line segment between a and b
+func segmentDistance(px, py, ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, ((px-ax)*dx+(py-ay)*dy)/length))
+	}
+	return math.Hypot(px-(ax+t*dx), py-(ay+t*dy))
+}
+
+// projection maps locations near an origin on to a plane with distances in meters
+type projection struct {
+	origin api.Location
+	cosLat float64
+}
+
+func newProjection(origin *api.Location) *projection {
+	return &projection{
+		origin: *origin,
+		cosLat: math.Cos(origin.Lat * math.Pi / 180),
+	}
+}
+
+// longitude wraps a longitude in degrees in to the range from -180 to 180
+func longitude(degrees float64) float64 {
+	return (s1.Angle(degrees) * s1.Degree).Normalized().Degrees()
+}
+
+// toPlane maps the location to the plane, longitudes are measured the short way around the antimeridian
+func (p *projection) toPlane(l *api.Location) (x, y float64) {
+	x = longitude(l.Lon-p.origin.Lon) * math.Pi / 180 * api.EarthRadiusInMeters * p.cosLat
+	y = (l.Lat - p.origin.Lat) * math.Pi / 180 * api.EarthRadiusInMeters
+	return x, y
+}
+
+func (p *projection) toLocation(x, y float64) api.Location {
+	return api.Location{
+		Lon:      longitude(p.origin.Lon + x/(api.EarthRadiusInMeters*p.cosLat)*180/math.Pi),
+		Lat:      p.origin.Lat + y/api.EarthRadiusInMeters*180/math.Pi,
+		Alt:      p.origin.Alt,
+		Accuracy: p.origin.Accuracy,
+	}
+}
@@ -0,0 +1,9 @@
+package plan
+
+import "errors"
+
+// ErrInvalidRadius happens when the scan radius is not positive
+var ErrInvalidRadius = errors.New("The scan radius must be positive")
+
+// ErrTooManyLocations happens when an area needs more than MaxLocations scan locations to be covered
+var ErrTooManyLocations = errors.New("The area needs too many scan locations to be covered with the scan radius")
@@ -0,0 +1,105 @@
+package plan
+
+import (
+	"math"
+
+	"github.com/golang/geo/r1"
+	"github.com/golang/geo/s1"
+	"github.com/golang/geo/s2"
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+// MaxLocations is the most scan locations a plan may have
+const MaxLocations = 10000
+
+// lonSpan returns the width in degrees of the bounding box, measured eastwards across the antimeridian when it
+// is crossed
+func lonSpan(box *api.BoundingBox) float64 {
+	if box.MinLon > box.MaxLon {
+		return box.MaxLon + 360 - box.MinLon
+	}
+	return box.MaxLon - box.MinLon
+}
+
+// Hexagonal returns the scan locations of a hexagonal grid where circles with the scan radius in meters cover the area,
+// the error is ErrTooManyLocations when the grid over the bounds of the area has more than MaxLocations locations
+func Hexagonal(area Area, radius float64) ([]api.Location, error) {
+	if !(radius > 0) {
+		return nil, ErrInvalidRadius
+	}
+
+	box := area.Bounds()
+	span := lonSpan(box)
+	proj := newProjection(&api.Location{
+		Lat: (box.MinLat + box.MaxLat) / 2,
+		Lon: longitude(box.MinLon + span/2),
+	})
+	_, minY := proj.toPlane(&api.Location{Lat: box.MinLat, Lon: proj.origin.Lon})
+	_, maxY := proj.toPlane(&api.Location{Lat: box.MaxLat, Lon: proj.origin.Lon})
+	// The width is measured from the center so boxes crossing the antimeridian are not flipped
+	maxX := span / 2 * math.Pi / 180 * api.EarthRadiusInMeters * proj.cosLat
+	minX := -maxX
+
+	// Circles centered on a hexagonal grid cover the plane when neighbours are sqrt(3) radii apart
+	dx := math.Sqrt(3) * radius
+	dy := 1.5 * radius
+
+	rows := math.Floor((maxY-minY+2*radius)/dy) + 1
+	columns := math.Floor((maxX-minX+2*radius)/dx) + 1
+	if !(rows*columns <= MaxLocations) {
+		return nil, ErrTooManyLocations
+	}
+
+	locations := make([]api.Location, 0)
+	for row, y := 0, minY-radius; y <= maxY+radius; row, y = row+1, y+dy {
+		offset := 0.0
+		if row%2 == 1 {
+			offset = dx / 2
+		}
+		for x := minX - radius + offset; x <= maxX+radius; x += dx {
+			l := proj.toLocation(x, y)
+			if area.DistanceTo(&l) <= radius {
+				locations = append(locations, l)
+			}
+		}
+	}
+
+	return locations, nil
+}
+
+// Cells returns the centers and ids of the cells at the cell level that cover the area, the error is
+// api.ErrTooManyCells when the area is too large for the cell level
+func Cells(area Area, level int) ([]api.Location, api.CellIDs, error) {
+	box := area.Bounds()
+	rect := s2.Rect{
+		Lat: r1.Interval{Lo: box.MinLat * math.Pi / 180, Hi: box.MaxLat * math.Pi / 180},
+		// An inverted longitude interval crosses the antimeridian
+		Lng: s1.IntervalFromEndpoints(box.MinLon*math.Pi/180, box.MaxLon*math.Pi/180),
+	}
+
+	covering, err := api.CoverRegion(rect, level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	locations := make([]api.Location, 0)
+	cellIDs := make(api.CellIDs, 0)
+	for _, cellID := range covering {
+		cell := s2.CellFromCellID(s2.CellID(cellID))
+		center := s2.LatLngFromPoint(cell.Center())
+		l := api.Location{Lat: center.Lat.Degrees(), Lon: center.Lng.Degrees()}
+
+		// The cell overlaps the area if the area is closer than the farthest vertex of the cell
+		reach := 0.0
+		for k := 0; k < 4; k++ {
+			vertex := s2.LatLngFromPoint(cell.Vertex(k))
+			reach = math.Max(reach, l.DistanceToCoordinates(vertex.Lat.Degrees(), vertex.Lng.Degrees()))
+		}
+		if area.DistanceTo(&l) <= reach {
+			locations = append(locations, l)
+			cellIDs = append(cellIDs, cellID)
+		}
+	}
+
+	return locations, cellIDs, nil
+}
@@ -0,0 +1,143 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+func assertCovered(t *testing.T, area Area, locations []api.Location, radius float64) {
+	box := area.Bounds()
+	for i := 0.0; i <= 1; i += 0.05 {
+		for j := 0.0; j <= 1; j += 0.05 {
+			p := api.Location{
+				Lat: box.MinLat + i*(box.MaxLat-box.MinLat),
+				Lon: box.MinLon + j*(box.MaxLon-box.MinLon),
+			}
+			if area.DistanceTo(&p) > 0 {
+				continue
+			}
+			covered := false
+			for idx := range locations {
+				if locations[idx].DistanceToLocation(&p) <= radius {
+					covered = true
+					break
+				}
+			}
+			if !covered {
+				t.Errorf("Expected %v to be covered", p)
+			}
+		}
+	}
+}
+
+func TestHexagonalCircle(t *testing.T) {
+	area := &Circle{Center: api.Location{Lat: 59.3293, Lon: 18.0686}, Radius: 1000}
+	locations, err := Hexagonal(area, 70)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) == 0 {
+		t.Fatal("Expected scan locations")
+	}
+	assertCovered(t, area, locations, 70)
+}
+
+func TestHexagonalPolygon(t *testing.T) {
+	area := &Polygon{Rings: [][]api.Location{
+		{{Lat: 59.32, Lon: 18.06}, {Lat: 59.32, Lon: 18.08}, {Lat: 59.33, Lon: 18.08}, {Lat: 59.33, Lon: 18.06}},
+		{{Lat: 59.323, Lon: 18.065}, {Lat: 59.323, Lon: 18.07}, {Lat: 59.327, Lon: 18.07}, {Lat: 59.327, Lon: 18.065}},
+	}}
+	locations, err := Hexagonal(area, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertCovered(t, area, locations, 100)
+
+	hole := api.Location{Lat: 59.325, Lon: 18.0675}
+	if area.DistanceTo(&hole) == 0 {
+		t.Error("Expected the hole not to be part of the area")
+	}
+}
+
+func TestCells(t *testing.T) {
+	area := &Circle{Center: api.Location{Lat: 59.3293, Lon: 18.0686}, Radius: 500}
+	locations, cellIDs, err := Cells(area, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != len(cellIDs) || len(cellIDs) == 0 {
+		t.Fatalf("Expected a center for each of the cells, got %d centers and %d cells", len(locations), len(cellIDs))
+	}
+	for idx := 1; idx < len(cellIDs); idx++ {
+		if cellIDs[idx-1] >= cellIDs[idx] {
+			t.Error("Expected cell ids to be sorted and unique")
+		}
+	}
+}
+
+func TestAntimeridian(t *testing.T) {
+	area := &Circle{Center: api.Location{Lat: 10, Lon: 179.995}, Radius: 2000}
+	if box := area.Bounds(); box.MinLon <= box.MaxLon {
+		t.Fatalf("Expected the bounding box to cross the antimeridian, got %v", box)
+	}
+
+	locations, err := Hexagonal(area, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for bearing := 0.0; bearing < 360; bearing += 15 {
+		for _, distance := range []float64{0, 1000, 1990} {
+			p := area.Center.Destination(bearing, distance)
+			covered := false
+			for idx := range locations {
+				if locations[idx].Lon < -180 || locations[idx].Lon > 180 {
+					t.Fatalf("Expected normalized longitudes, got %v", locations[idx])
+				}
+				if locations[idx].DistanceToLocation(p) <= 200 {
+					covered = true
+					break
+				}
+			}
+			if !covered {
+				t.Errorf("Expected %v to be covered", p)
+			}
+		}
+	}
+
+	centers, cellIDs, err := Cells(area, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	east, west := false, false
+	for _, l := range centers {
+		east = east || l.Lon > 0
+		west = west || l.Lon < 0
+	}
+	if len(cellIDs) == 0 || len(cellIDs) > 400 || !east || !west {
+		t.Errorf("Expected the cells either side of the antimeridian, got %d cells", len(cellIDs))
+	}
+}
+
+func TestCellsTooMany(t *testing.T) {
+	area := &Circle{Center: api.Location{Lat: 59.3293, Lon: 18.0686}, Radius: 50000}
+	if _, _, err := Cells(area, 17); err != api.ErrTooManyCells {
+		t.Errorf("Expected too many cells for a large area at level 17, got %v", err)
+	}
+}
+
+func TestHexagonalInvalidRadius(t *testing.T) {
+	area := &Circle{Center: api.Location{Lat: 59.3293, Lon: 18.0686}, Radius: 1000}
+	for _, radius := range []float64{0, -70} {
+		if _, err := Hexagonal(area, radius); err != ErrInvalidRadius {
+			t.Errorf("Expected an invalid radius error for radius %f, got %v", radius, err)
+		}
+	}
+}
+
+func TestHexagonalTooMany(t *testing.T) {
+	area := &Circle{Center: api.Location{Lat: 59.3293, Lon: 18.0686}, Radius: 50000}
+	if _, err := Hexagonal(area, 70); err != ErrTooManyLocations {
+		t.Errorf("Expected too many locations for a large area, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package route
+
+import (
+	"io"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+	"github.com/pogodevorg/pgoapi-go/api"
+	"github.com/pogodevorg/pgoapi-go/geo"
+)
+
+const maxImprovementPasses = 100
+
+// Route is an order of visiting forts from a start location
+type Route struct {
+	// Forts in the order of visiting
+	Forts []*protos.FortData
+	// Locations of the start followed by the forts in the order of visiting
+	Locations []api.Location
+	// Legs are the distances in meters between each location and the next
+	Legs []float64
+	// Distance is the total distance in meters of the route
+	Distance float64
+}
+
+// FortsFromMap returns all forts in the map cells of the response
+func FortsFromMap(mapObjects *protos.GetMapObjectsResponse) []*protos.FortData {
+	seen := make(map[string]bool)
+	forts := make([]*protos.FortData, 0)
+	for _, cell := range mapObjects.GetMapCells() {
+		for _, fort := range cell.GetForts() {
+			if seen[fort.Id] {
+				continue
+			}
+			seen[fort.Id] = true
+			forts = append(forts, fort)
+		}
+	}
+	return forts
+}
+
+// Optimize returns a short route visiting all forts from the start location, starting at the
+// nearest neighbours and improving the order by reversing parts of the route (2-opt)
+func Optimize(start *api.Location, forts []*protos.FortData) *Route {
+	locations := make([]api.Location, len(forts)+1)
+	locations[0] = *start
+	for idx, fort := range forts {
+		locations[idx+1] = api.Location{Lat: fort.Latitude, Lon: fort.Longitude}
+	}
+
+	distances := make([][]float64, len(locations))
+	for i := range locations {
+		distances[i] = make([]float64, len(locations))
+		for j := range locations {
+			distances[i][j] = locations[i].DistanceToLocation(&locations[j])
+		}
+	}
+
+	order := nearestNeighbour(distances)
+	improve(order, distances)
+
+	route := &Route{
+		Forts:     make([]*protos.FortData, len(forts)),
+		Locations: make([]api.Location, len(order)),
+		Legs:      make([]float64, len(order)-1),
+	}
+	for idx, stop := range order {
+		route.Locations[idx] = locations[stop]
+		if idx > 0 {
+			route.Forts[idx-1] = forts[stop-1]
+			route.Legs[idx-1] = distances[order[idx-1]][stop]
+			route.Distance += route.Legs[idx-1]
+		}
+	}
+
+	return route
+}
+
+// nearestNeighbour returns an order starting at the first location and always going to the closest unvisited location
+func nearestNeighbour(distances [][]float64) []int {
+	visited := make([]bool, len(distances))
+	order := make([]int, 0, len(distances))
+	current := 0
+	visited[current] = true
+	order = append(order, current)
+	for len(order) < len(distances) {
+		next := -1
+		for candidate := range distances {
+			if !visited[candidate] && (next < 0 || distances[current][candidate] < distances[current][next]) {
+				next = candidate
+			}
+		}
+		visited[next] = true
+		order = append(order, next)
+		current = next
+	}
+	return order
+}
+
+// improve reverses parts of the order as long as it makes the open path shorter, the start stays first
+func improve(order []int, distances [][]float64) {
+	for pass := 0; pass < maxImprovementPasses; pass++ {
+		improved := false
+		for i := 1; i < len(order)-1; i++ {
+			for j := i + 1; j < len(order); j++ {
+				before := distances[order[i-1]][order[i]]
+				after := distances[order[i-1]][order[j]]
+				if j+1 < len(order) {
+					before += distances[order[j]][order[j+1]]
+					after += distances[order[i]][order[j+1]]
+				}
+				if after < before-1e-9 {
+					reverse(order[i : j+1])
+					improved = true
+				}
+			}
+		}
+		if !improved {
+			return
+		}
+	}
+}
+
+func reverse(order []int) {
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+}
+
+// GPX writes the route as a GPX document with a waypoint for each fort
+func (r *Route) GPX(w io.Writer) error {
+	doc := geo.NewGPX()
+	for idx, fort := range r.Forts {
+		doc.AddWaypoint(r.Locations[idx+1], fort.Id)
+	}
+	doc.AddRoute("Fort route", r.Locations)
+	return doc.Encode(w)
+}
@@ -0,0 +1,95 @@
+package route
+
+import (
+	"bytes"
+	"encoding/xml"
+	"math"
+	"testing"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+func TestOptimize(t *testing.T) {
+	start := &api.Location{Lat: 0, Lon: 0}
+	forts := []*protos.FortData{
+		{Id: "c", Latitude: 0, Longitude: 0.003},
+		{Id: "a", Latitude: 0, Longitude: 0.001},
+		{Id: "e", Latitude: 0, Longitude: 0.005},
+		{Id: "b", Latitude: 0, Longitude: 0.002},
+		{Id: "d", Latitude: 0, Longitude: 0.004},
+	}
+
+	route := Optimize(start, forts)
+
+	order := ""
+	for _, fort := range route.Forts {
+		order += fort.Id
+	}
+	if order != "abcde" {
+		t.Errorf("Expected forts to be visited in order abcde, got %s", order)
+	}
+	if len(route.Locations) != len(forts)+1 || len(route.Legs) != len(forts) {
+		t.Fatalf("Expected %d locations and %d legs, got %d and %d", len(forts)+1, len(forts), len(route.Locations), len(route.Legs))
+	}
+
+	expected := start.DistanceToCoordinates(0, 0.005)
+	if math.Abs(route.Distance-expected) > 1e-6 {
+		t.Errorf("Expected distance %f, got %f", expected, route.Distance)
+	}
+
+	var out bytes.Buffer
+	err := route.GPX(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Waypoints []struct{} `xml:"wpt"`
+	}
+	err = xml.Unmarshal(out.Bytes(), &doc)
+	if err != nil || len(doc.Waypoints) != len(forts) {
+		t.Errorf("Expected a GPX waypoint for each fort, got %s", out.String())
+	}
+}
+
+func TestOptimizeUncrossesNearestNeighbour(t *testing.T) {
+	// Going to the nearest fort first leads to a path that crosses itself, only reversing part of it finds
+	// the shortest order
+	start := &api.Location{Lat: 0, Lon: 0}
+	forts := []*protos.FortData{
+		{Id: "a", Latitude: 0.002, Longitude: 0.002},
+		{Id: "b", Latitude: 0.002, Longitude: 0.003},
+		{Id: "c", Latitude: -0.002, Longitude: 0.001},
+		{Id: "d", Latitude: -0.003, Longitude: -0.004},
+		{Id: "e", Latitude: -0.002, Longitude: 0.003},
+	}
+
+	route := Optimize(start, forts)
+	order := ""
+	for _, fort := range route.Forts {
+		order += fort.Id
+	}
+	if order != "abecd" {
+		t.Errorf("Expected forts to be visited in order abecd, got %s", order)
+	}
+
+	distances := make([][]float64, len(route.Locations))
+	locations := append([]api.Location{*start}, make([]api.Location, len(forts))...)
+	for idx, fort := range forts {
+		locations[idx+1] = api.Location{Lat: fort.Latitude, Lon: fort.Longitude}
+	}
+	for i := range locations {
+		distances[i] = make([]float64, len(locations))
+		for j := range locations {
+			distances[i][j] = locations[i].DistanceToLocation(&locations[j])
+		}
+	}
+	nearest := 0.0
+	first := nearestNeighbour(distances)
+	for idx := 1; idx < len(first); idx++ {
+		nearest += distances[first[idx-1]][first[idx]]
+	}
+	if route.Distance >= nearest {
+		t.Errorf("Expected the route of %f meters to be shorter than the nearest neighbour route of %f meters", route.Distance, nearest)
+	}
+}
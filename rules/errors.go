@@ -0,0 +1,19 @@
+package rules
+
+import "fmt"
+
+// ErrSyntax happens when an expression or a rules file cannot be parsed
+type ErrSyntax struct {
+	// Line is the line of the rules file, or zero for a single expression
+	Line int
+	// Pos is the byte offset in the expression where parsing failed
+	Pos     int
+	Message string
+}
+
+func (e *ErrSyntax) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("rules: line %d: %s at position %d", e.Line, e.Message, e.Pos)
+	}
+	return fmt.Sprintf("rules: %s at position %d", e.Message, e.Pos)
+}
@@ -0,0 +1,516 @@
+package rules
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+	"github.com/pogodevorg/pgoapi-go/geo"
+	"github.com/pogodevorg/pgoapi-go/plan"
+)
+
+// node is a part of an expression that evaluates to a number, string, bool or nil when a field is missing
+type node interface {
+	eval(s *subject) interface{}
+}
+
+type literal struct {
+	value interface{}
+}
+
+func (n *literal) eval(s *subject) interface{} {
+	return n.value
+}
+
+type field struct {
+	name string
+}
+
+func (n *field) eval(s *subject) interface{} {
+	return s.field(n.name)
+}
+
+type logical struct {
+	and         bool
+	left, right node
+}
+
+func (n *logical) eval(s *subject) interface{} {
+	left := n.left.eval(s) == true
+	if n.and && !left {
+		return false
+	}
+	if !n.and && left {
+		return true
+	}
+	return n.right.eval(s) == true
+}
+
+type negation struct {
+	operand node
+}
+
+func (n *negation) eval(s *subject) interface{} {
+	return n.operand.eval(s) != true
+}
+
+type comparison struct {
+	operator    string
+	left, right node
+}
+
+func (n *comparison) eval(s *subject) interface{} {
+	return compare(n.operator, n.left.eval(s), n.right.eval(s))
+}
+
+// compare tells if the values compare with the operator, values of different kinds or missing values never do
+func compare(operator string, left, right interface{}) bool {
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return false
+		}
+		switch operator {
+		case "==":
+			return l == r
+		case "!=":
+			return l != r
+		case "<":
+			return l < r
+		case "<=":
+			return l <= r
+		case ">":
+			return l > r
+		case ">=":
+			return l >= r
+		}
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return false
+		}
+		switch operator {
+		case "==":
+			return l == r
+		case "!=":
+			return l != r
+		case "<":
+			return l < r
+		case "<=":
+			return l <= r
+		case ">":
+			return l > r
+		case ">=":
+			return l >= r
+		}
+	case bool:
+		r, ok := right.(bool)
+		if !ok {
+			return false
+		}
+		switch operator {
+		case "==":
+			return l == r
+		case "!=":
+			return l != r
+		}
+	}
+	return false
+}
+
+var comparisonOperators = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+type membership struct {
+	operand node
+	set     map[interface{}]bool
+}
+
+func (n *membership) eval(s *subject) interface{} {
+	value := n.operand.eval(s)
+	return value != nil && n.set[value]
+}
+
+type distance struct {
+	lat, lon float64
+}
+
+func (n *distance) eval(s *subject) interface{} {
+	location := s.location()
+	if location == nil {
+		return nil
+	}
+	return location.DistanceToCoordinates(n.lat, n.lon)
+}
+
+type inBoundingBox struct {
+	box *api.BoundingBox
+}
+
+func (n *inBoundingBox) eval(s *subject) interface{} {
+	location := s.location()
+	return location != nil && n.box.Contains(location)
+}
+
+type inPolygon struct {
+	polygon *plan.Polygon
+}
+
+func (n *inPolygon) eval(s *subject) interface{} {
+	location := s.location()
+	return location != nil && n.polygon.Contains(location)
+}
+
+// fields are the names of the fields an expression can refer to
+var fields = map[string]bool{
+	"type":          true,
+	"species":       true,
+	"lat":           true,
+	"lon":           true,
+	"team":          true,
+	"previous_team": true,
+	"fort_id":       true,
+	"fort_type":     true,
+	"gym_points":    true,
+	"lured":         true,
+	"despawn_in":    true,
+	"username":      true,
+	"provider":      true,
+	"request_type":  true,
+}
+
+// constant returns the value of a Pokémon, team, fort type or request type name
+func constant(name string) (float64, bool) {
+	for _, values := range []map[string]int32{protos.PokemonId_value, protos.TeamColor_value, protos.FortType_value, protos.RequestType_value} {
+		if value, ok := values[name]; ok {
+			return float64(value), true
+		}
+	}
+	return 0, false
+}
+
+// Expression is a compiled condition on feed entries
+type Expression struct {
+	source string
+	root   node
+}
+
+// Compile parses the expression, polygon files are read relative to the working directory
+func Compile(expression string) (*Expression, error) {
+	return compile(expression, "")
+}
+
+func compile(expression, dir string) (*Expression, error) {
+	tokens, err := lex(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, dir: dir}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &ErrSyntax{Pos: t.pos, Message: "unexpected " + describe(t)}
+	}
+	return &Expression{source: expression, root: root}, nil
+}
+
+// Match tells if the message, received with the entry, satisfies the expression
+func (e *Expression) Match(message interface{}, entry *api.FeedEntry) bool {
+	return e.root.eval(&subject{message: message, entry: entry}) == true
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	dir    string
+}
+
+func describe(t token) string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return "\"" + t.text + "\""
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword tells if the next token is the keyword and consumes it when it is
+func (p *parser) keyword(word string) bool {
+	if t := p.peek(); t.kind == tokenIdent && t.text == word {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(operator string) error {
+	t := p.next()
+	if t.kind != tokenOperator || t.text != operator {
+		return &ErrSyntax{Pos: t.pos, Message: "expected \"" + operator + "\" but found " + describe(t)}
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.keyword("not") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &negation{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	switch {
+	case t.kind == tokenOperator && comparisonOperators[t.text]:
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &comparison{operator: t.text, left: left, right: right}, nil
+	case t.kind == tokenIdent && t.text == "in":
+		p.next()
+		return p.parseSet(left)
+	case t.kind == tokenIdent && t.text == "not" && p.tokens[p.pos+1].kind == tokenIdent && p.tokens[p.pos+1].text == "in":
+		p.pos += 2
+		set, err := p.parseSet(left)
+		if err != nil {
+			return nil, err
+		}
+		return &negation{operand: set}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseSet(operand node) (node, error) {
+	err := p.expect("{")
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[interface{}]bool)
+	for {
+		t := p.peek()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, &ErrSyntax{Pos: t.pos, Message: "expected a value but found " + describe(t)}
+		}
+		set[value] = true
+
+		t = p.next()
+		if t.kind == tokenOperator && t.text == "}" {
+			break
+		}
+		if t.kind != tokenOperator || t.text != "," {
+			return nil, &ErrSyntax{Pos: t.pos, Message: "expected \",\" or \"}\" but found " + describe(t)}
+		}
+	}
+	return &membership{operand: operand, set: set}, nil
+}
+
+// parseValue returns the value of a number, string, boolean or constant, and nil for any other token
+func (p *parser) parseValue() (interface{}, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenNumber, tokenString:
+		p.next()
+		return t.value, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			p.next()
+			return true, nil
+		case "false":
+			p.next()
+			return false, nil
+		}
+		if value, ok := constant(t.text); ok {
+			p.next()
+			return value, nil
+		}
+		if strings.ToUpper(t.text) == t.text {
+			return nil, &ErrSyntax{Pos: t.pos, Message: "unknown constant " + describe(t)}
+		}
+	}
+	return nil, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if value != nil {
+		return &literal{value: value}, nil
+	}
+
+	t := p.next()
+	switch {
+	case t.kind == tokenOperator && t.text == "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	case t.kind == tokenIdent && p.peek().kind == tokenOperator && p.peek().text == "(":
+		return p.parseCall(t)
+	case t.kind == tokenIdent && fields[t.text]:
+		return &field{name: t.text}, nil
+	case t.kind == tokenIdent:
+		return nil, &ErrSyntax{Pos: t.pos, Message: "unknown field " + describe(t)}
+	}
+	return nil, &ErrSyntax{Pos: t.pos, Message: "unexpected " + describe(t)}
+}
+
+// parseArguments returns the literal arguments of a function call
+func (p *parser) parseArguments() ([]interface{}, error) {
+	err := p.expect("(")
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, 0)
+	if t := p.peek(); t.kind == tokenOperator && t.text == ")" {
+		p.next()
+		return args, nil
+	}
+	for {
+		t := p.peek()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, &ErrSyntax{Pos: t.pos, Message: "expected a value but found " + describe(t)}
+		}
+		args = append(args, value)
+
+		t = p.next()
+		if t.kind == tokenOperator && t.text == ")" {
+			return args, nil
+		}
+		if t.kind != tokenOperator || t.text != "," {
+			return nil, &ErrSyntax{Pos: t.pos, Message: "expected \",\" or \")\" but found " + describe(t)}
+		}
+	}
+}
+
+func numbers(args []interface{}) ([]float64, bool) {
+	values := make([]float64, len(args))
+	for idx, arg := range args {
+		value, ok := arg.(float64)
+		if !ok {
+			return nil, false
+		}
+		values[idx] = value
+	}
+	return values, true
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	args, err := p.parseArguments()
+	if err != nil {
+		return nil, err
+	}
+
+	switch name.text {
+	case "distance":
+		values, ok := numbers(args)
+		if !ok || len(values) != 2 {
+			return nil, &ErrSyntax{Pos: name.pos, Message: "distance takes a latitude and a longitude"}
+		}
+		return &distance{lat: values[0], lon: values[1]}, nil
+	case "in_bbox":
+		values, ok := numbers(args)
+		if !ok || len(values) != 4 {
+			return nil, &ErrSyntax{Pos: name.pos, Message: "in_bbox takes a minimum latitude, minimum longitude, maximum latitude and maximum longitude"}
+		}
+		return &inBoundingBox{box: &api.BoundingBox{
+			MinLat: math.Min(values[0], values[2]),
+			MinLon: math.Min(values[1], values[3]),
+			MaxLat: math.Max(values[0], values[2]),
+			MaxLon: math.Max(values[1], values[3]),
+		}}, nil
+	case "in_polygon":
+		path, ok := "", len(args) == 1
+		if ok {
+			path, ok = args[0].(string)
+		}
+		if !ok {
+			return nil, &ErrSyntax{Pos: name.pos, Message: "in_polygon takes the path of a GeoJSON file"}
+		}
+		if p.dir != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(p.dir, path)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rings, err := geo.ParsePolygon(data)
+		if err != nil {
+			return nil, err
+		}
+		return &inPolygon{polygon: &plan.Polygon{Rings: rings}}, nil
+	}
+	return nil, &ErrSyntax{Pos: name.pos, Message: "unknown function " + describe(name)}
+}
@@ -0,0 +1,115 @@
+package rules
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "<", ">", "(", ")", "{", "}", ","}
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+// numberEnd returns the position after the digits, decimal points and exponent of a number starting before the position
+func numberEnd(expression string, pos int) int {
+	for pos < len(expression) && (isDigit(expression[pos]) || expression[pos] == '.') {
+		pos++
+	}
+	// The exponent is only part of the number when it has digits, like in 1e-5
+	if pos < len(expression) && (expression[pos] == 'e' || expression[pos] == 'E') {
+		end := pos + 1
+		if end < len(expression) && (expression[end] == '+' || expression[end] == '-') {
+			end++
+		}
+		if end < len(expression) && isDigit(expression[end]) {
+			pos = end
+			for pos < len(expression) && isDigit(expression[pos]) {
+				pos++
+			}
+		}
+	}
+	return pos
+}
+
+// lex splits the expression in to tokens
+func lex(expression string) ([]token, error) {
+	tokens := make([]token, 0)
+	pos := 0
+	for pos < len(expression) {
+		c, size := utf8.DecodeRuneInString(expression[pos:])
+		switch {
+		case unicode.IsSpace(c):
+			pos += size
+		case c == '"':
+			end := pos + 1
+			for end < len(expression) && expression[end] != '"' {
+				if expression[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expression) {
+				return nil, &ErrSyntax{Pos: pos, Message: "unterminated string"}
+			}
+			value, err := strconv.Unquote(expression[pos : end+1])
+			if err != nil {
+				return nil, &ErrSyntax{Pos: pos, Message: "invalid string"}
+			}
+			tokens = append(tokens, token{kind: tokenString, text: expression[pos : end+1], value: value, pos: pos})
+			pos = end + 1
+		case isDigit(expression[pos]) || c == '.' || (c == '-' && pos+1 < len(expression) && (isDigit(expression[pos+1]) || expression[pos+1] == '.')):
+			end := numberEnd(expression, pos+1)
+			value, err := strconv.ParseFloat(expression[pos:end], 64)
+			if err != nil {
+				return nil, &ErrSyntax{Pos: pos, Message: "invalid number"}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: expression[pos:end], value: value, pos: pos})
+			pos = end
+		case unicode.IsLetter(c) || c == '_':
+			end := pos + size
+			for end < len(expression) {
+				r, n := utf8.DecodeRuneInString(expression[end:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+					break
+				}
+				end += n
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: expression[pos:end], pos: pos})
+			pos = end
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(expression[pos:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: pos})
+					pos += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, &ErrSyntax{Pos: pos, Message: "unexpected character " + strconv.QuoteRune(c)}
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: pos}), nil
+}
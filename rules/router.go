@@ -0,0 +1,128 @@
+package rules
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+)
+
+// Rule routes the entries matching the expression to the feed
+type Rule struct {
+	Expression *Expression
+	Target     string
+	Feed       api.Feed
+}
+
+// Router is a feed pushing every entry to the feeds of all rules it matches. Map objects responses are split in
+// to their wild Pokémon and forts, which are matched and pushed on their own.
+type Router struct {
+	rules []*Rule
+}
+
+// NewRouter constructs a router for the rules
+func NewRouter(rules []*Rule) *Router {
+	return &Router{rules: rules}
+}
+
+// split returns the messages of the entry that are matched against the rules
+func split(entry *api.FeedEntry) []interface{} {
+	mapObjects, ok := entry.Message.(*protos.GetMapObjectsResponse)
+	if !ok {
+		return []interface{}{entry.Message}
+	}
+
+	timestamp := entry.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	snapshot := api.NewMapSnapshot(mapObjects, &entry.Location, timestamp)
+	messages := make([]interface{}, 0, len(snapshot.WildPokemons)+len(snapshot.Forts))
+	for _, pokemon := range snapshot.WildPokemons {
+		messages = append(messages, pokemon.Pokemon)
+	}
+	for _, fort := range snapshot.Forts {
+		messages = append(messages, fort.Fort)
+	}
+	return messages
+}
+
+// PushEntry pushes the messages of the entry to the feeds of the rules they match
+func (r *Router) PushEntry(entry *api.FeedEntry) {
+	for _, message := range split(entry) {
+		for _, rule := range r.rules {
+			if !rule.Expression.Match(message, entry) {
+				continue
+			}
+			routed := *entry
+			routed.Message = message
+			if entryFeed, ok := rule.Feed.(api.EntryFeed); ok {
+				entryFeed.PushEntry(&routed)
+			} else {
+				rule.Feed.Push(message)
+			}
+		}
+	}
+}
+
+// Push pushes the messages of the entry to the feeds of the rules they match
+func (r *Router) Push(entry interface{}) {
+	r.PushEntry(&api.FeedEntry{Message: entry})
+}
+
+// ParseRules reads rules of the form "<expression> -> <target>", one per line. Empty lines and lines starting
+// with # are skipped. The feed of each target is returned by resolve, and polygon files are read relative to dir.
+func ParseRules(r io.Reader, dir string, resolve func(target string) (api.Feed, error)) ([]*Rule, error) {
+	rules := make([]*Rule, 0)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		arrow := strings.LastIndex(text, "->")
+		if arrow < 0 {
+			return nil, &ErrSyntax{Line: line, Pos: len(text), Message: "expected \"->\" and a target"}
+		}
+		target := strings.TrimSpace(text[arrow+2:])
+		if target == "" {
+			return nil, &ErrSyntax{Line: line, Pos: len(text), Message: "expected a target"}
+		}
+
+		expression, err := compile(text[:arrow], dir)
+		if err != nil {
+			if syntax, ok := err.(*ErrSyntax); ok {
+				syntax.Line = line
+			}
+			return nil, err
+		}
+		expression.source = strings.TrimSpace(expression.source)
+
+		feed, err := resolve(target)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, &Rule{Expression: expression, Target: target, Feed: feed})
+	}
+	return rules, scanner.Err()
+}
+
+// LoadRules reads the rules from the file, polygon files are read relative to the directory of the file
+func LoadRules(path string, resolve func(target string) (api.Feed, error)) ([]*Rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseRules(file, filepath.Dir(path), resolve)
+}
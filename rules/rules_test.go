@@ -0,0 +1,301 @@
+package rules
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+	"github.com/pogodevorg/pgoapi-go/feed"
+)
+
+type recordFeed struct {
+	entries []*api.FeedEntry
+}
+
+func (f *recordFeed) PushEntry(entry *api.FeedEntry) {
+	f.entries = append(f.entries, entry)
+}
+
+func (f *recordFeed) Push(entry interface{}) {
+	f.PushEntry(&api.FeedEntry{Message: entry})
+}
+
+func wildPokemon(id protos.PokemonId, lat, lon float64) *protos.WildPokemon {
+	return &protos.WildPokemon{
+		EncounterId:      uint64(id),
+		Latitude:         lat,
+		Longitude:        lon,
+		PokemonData:      &protos.PokemonData{PokemonId: id},
+		TimeTillHiddenMs: 300000,
+	}
+}
+
+func TestExpression(t *testing.T) {
+	pidgey := wildPokemon(protos.PokemonId_PIDGEY, 0.001, 0.001)
+	gym := &protos.FortData{Id: "gym", Type: protos.FortType_GYM, OwnedByTeam: protos.TeamColor_RED, GymPoints: 2000, Latitude: 0.5, Longitude: 0.5}
+	changed := &api.GymChangedEvent{
+		FortID:   "gym",
+		Previous: &protos.FortData{Id: "gym", Type: protos.FortType_GYM, OwnedByTeam: protos.TeamColor_BLUE},
+		Current:  gym,
+	}
+	entry := &api.FeedEntry{Username: "ash", RequestType: protos.RequestType_GET_MAP_OBJECTS}
+
+	tests := []struct {
+		expression string
+		message    interface{}
+		matches    bool
+	}{
+		{`species in {PIDGEY, RATTATA}`, pidgey, true},
+		{`species not in {PIDGEY, RATTATA}`, pidgey, false},
+		{`species == PIKACHU`, pidgey, false},
+		{`species in {PIDGEY} and distance(0, 0) < 500`, pidgey, true},
+		{`species in {PIDGEY} and distance(0.1, 0.1) < 500`, pidgey, false},
+		{`despawn_in >= 300 and type == "POGOProtos.Map.Pokemon.WildPokemon"`, pidgey, true},
+		{`in_bbox(0, 0, 0.01, 0.01)`, pidgey, true},
+		{`in_bbox(0.01, 0.01, 0.02, 0.02)`, pidgey, false},
+		{`type == "api.GymChangedEvent" and team != previous_team`, changed, true},
+		{`team == RED and previous_team == BLUE and gym_points > 1000`, changed, true},
+		{`previous_team == BLUE`, gym, false},
+		{`not (team == BLUE or team == YELLOW)`, gym, true},
+		{`fort_type == GYM and not lured`, gym, true},
+		{`team == RED`, pidgey, false},
+		{`username == "ash" and request_type == GET_MAP_OBJECTS`, pidgey, true},
+		{`lat > -0.5 and lon < 1`, pidgey, true},
+		{`lat > 1e-5 and distance(0, 0) < 5E+2`, pidgey, true},
+		{`lat < 1e-5`, pidgey, false},
+	}
+
+	for _, test := range tests {
+		expression, err := Compile(test.expression)
+		if err != nil {
+			t.Errorf("Expected %q to compile, got %s", test.expression, err)
+			continue
+		}
+		if matches := expression.Match(test.message, entry); matches != test.matches {
+			t.Errorf("Expected %q to match %T %t, got %t", test.expression, test.message, test.matches, matches)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, expression := range []string{
+		``,
+		`species ==`,
+		`species in {PIDGEY`,
+		`species in {}`,
+		`height > 3`,
+		`species == NOT_A_POKEMON`,
+		`distance(0) < 500`,
+		`in_polygon(3)`,
+		`(team == RED`,
+		`team == RED RED`,
+		`username == "ash`,
+		`team = RED`,
+		`unknown(1)`,
+	} {
+		_, err := Compile(expression)
+		if _, ok := err.(*ErrSyntax); !ok {
+			t.Errorf("Expected a syntax error for %q, got %v", expression, err)
+		}
+	}
+
+	_, err := Compile(`team == RED € 1`)
+	if e, ok := err.(*ErrSyntax); !ok || e.Pos != 12 || !strings.Contains(e.Message, "€") {
+		t.Errorf("Expected the unexpected character to be decoded, got %v", err)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	polygon := `{"type": "Polygon", "coordinates": [[[0, 0], [0, 1], [1, 1], [1, 0], [0, 0]]]}`
+	err = ioutil.WriteFile(filepath.Join(dir, "area.geojson"), []byte(polygon), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feeds := make(map[string]*recordFeed)
+	resolve := func(target string) (api.Feed, error) {
+		if feeds[target] == nil {
+			feeds[target] = &recordFeed{}
+		}
+		return feeds[target], nil
+	}
+
+	rules, err := ParseRules(strings.NewReader(`
+# Pidgeys close to the origin
+species in {PIDGEY, RATTATA} and distance(0, 0) < 500 -> nearby
+
+type == "api.GymChangedEvent" and team != previous_team and in_polygon("area.geojson") -> gyms
+type == "POGOProtos.Map.Fort.FortData" and fort_type == GYM -> forts
+type == "api.LureAddedEvent" and in_bbox(0, 0, 1, 1) -> lures
+`), dir, resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 4 || rules[0].Target != "nearby" || rules[0].Expression.String() != "species in {PIDGEY, RATTATA} and distance(0, 0) < 500" {
+		t.Fatalf("Expected four rules, got %#v", rules)
+	}
+
+	router := NewRouter(rules)
+	router.PushEntry(&api.FeedEntry{
+		Message: &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{
+			S2CellId: 1,
+			WildPokemons: []*protos.WildPokemon{
+				wildPokemon(protos.PokemonId_PIDGEY, 0.001, 0.001),
+				wildPokemon(protos.PokemonId_PIDGEY, 0.5, 0.5),
+				wildPokemon(protos.PokemonId_PIKACHU, 0.001, 0.001),
+			},
+			Forts: []*protos.FortData{{Id: "gym", Type: protos.FortType_GYM, Latitude: 0.5, Longitude: 0.5}},
+		}}},
+		Username:  "ash",
+		Timestamp: time.Unix(1000, 0),
+	})
+	router.PushEntry(&api.FeedEntry{
+		Message: &api.LureAddedEvent{
+			FortID: "pokestop",
+			Fort:   &protos.FortData{Id: "pokestop", Type: protos.FortType_CHECKPOINT, Latitude: 2, Longitude: 2},
+		},
+		Location: api.Location{Lat: 0.5, Lon: 0.5},
+	})
+	router.PushEntry(&api.FeedEntry{
+		Message: &api.LureAddedEvent{
+			FortID: "inside",
+			Fort:   &protos.FortData{Id: "inside", Type: protos.FortType_CHECKPOINT, Latitude: 0.5, Longitude: 0.5},
+		},
+		Location: api.Location{Lat: 2, Lon: 2},
+	})
+	router.Push(&api.GymChangedEvent{
+		FortID:   "gym",
+		Previous: &protos.FortData{Id: "gym", Type: protos.FortType_GYM, OwnedByTeam: protos.TeamColor_BLUE},
+		Current:  &protos.FortData{Id: "gym", Type: protos.FortType_GYM, OwnedByTeam: protos.TeamColor_RED, Latitude: 0.5, Longitude: 0.5},
+	})
+	router.Push(&api.GymChangedEvent{
+		FortID:   "outside",
+		Previous: &protos.FortData{Id: "outside", Type: protos.FortType_GYM, OwnedByTeam: protos.TeamColor_BLUE},
+		Current:  &protos.FortData{Id: "outside", Type: protos.FortType_GYM, OwnedByTeam: protos.TeamColor_RED, Latitude: 2, Longitude: 2},
+	})
+
+	nearby := feeds["nearby"].entries
+	if len(nearby) != 1 {
+		t.Fatalf("Expected one nearby Pokémon, got %d", len(nearby))
+	}
+	if pokemon, ok := nearby[0].Message.(*protos.WildPokemon); !ok || pokemon.Latitude != 0.001 || nearby[0].Username != "ash" {
+		t.Errorf("Expected the nearby Pidgey with its entry, got %#v", nearby[0])
+	}
+	if gyms := feeds["gyms"].entries; len(gyms) != 1 || gyms[0].Message.(*api.GymChangedEvent).FortID != "gym" {
+		t.Errorf("Expected the gym in the polygon to change team, got %#v", gyms)
+	}
+	if forts := feeds["forts"].entries; len(forts) != 1 {
+		t.Errorf("Expected the gym to be routed, got %d forts", len(forts))
+	}
+	if lures := feeds["lures"].entries; len(lures) != 1 || lures[0].Message.(*api.LureAddedEvent).FortID != "inside" {
+		t.Errorf("Expected lures to be matched at the location of their fort, got %#v", lures)
+	}
+}
+
+func TestReplayRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "feed.jsonl")
+	recording, err := feed.NewJSONLines(path, feed.JSONLinesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pidgey := wildPokemon(protos.PokemonId_PIDGEY, 0.001, 0.001)
+	pidgey.LastModifiedTimestampMs = 1000000
+	lured := &protos.FortData{Id: "pokestop", Type: protos.FortType_CHECKPOINT,
+		LureInfo: &protos.FortLureInfo{FortId: "pokestop", LureExpiresTimestampMs: 1200000}}
+	recording.PushEntry(&api.FeedEntry{
+		Message: &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{
+			S2CellId:     1,
+			WildPokemons: []*protos.WildPokemon{pidgey},
+			Forts:        []*protos.FortData{lured},
+		}}},
+		Timestamp: time.Unix(1000, 0),
+	})
+	recording.PushEntry(&api.FeedEntry{
+		Message: &protos.GetMapObjectsResponse{MapCells: []*protos.MapCell{{
+			S2CellId: 1,
+			Forts:    []*protos.FortData{{Id: "pokestop", Type: protos.FortType_CHECKPOINT}},
+		}}},
+		Timestamp: time.Unix(1060, 0),
+	})
+	err = recording.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feeds := make(map[string]*recordFeed)
+	resolve := func(target string) (api.Feed, error) {
+		if feeds[target] == nil {
+			feeds[target] = &recordFeed{}
+		}
+		return feeds[target], nil
+	}
+	rules, err := ParseRules(strings.NewReader(`
+type == "api.PokemonAppearedEvent" and despawn_in >= 300 -> appeared
+type == "api.LureAddedEvent" -> added
+type == "api.LureExpiredEvent" -> expired
+`), dir, resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := feed.NewJSONLinesReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = feed.Replay(context.Background(), reader, api.NewDiffFeed(NewRouter(rules)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The responses are diffed at the time they were recorded, not at the time they are replayed
+	for _, target := range []string{"appeared", "added", "expired"} {
+		if feeds[target] == nil || len(feeds[target].entries) != 1 {
+			t.Errorf("Expected one %s event to be replayed, got %v", target, feeds[target])
+		}
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	resolve := func(target string) (api.Feed, error) {
+		return &recordFeed{}, nil
+	}
+	for _, text := range []string{
+		"species == PIDGEY",
+		"species == PIDGEY ->",
+		"# comment\nspecies == -> stdout",
+	} {
+		_, err := ParseRules(strings.NewReader(text), "", resolve)
+		if _, ok := err.(*ErrSyntax); !ok {
+			t.Errorf("Expected a syntax error for %q, got %v", text, err)
+		}
+	}
+
+	_, err := ParseRules(strings.NewReader("\n\nteam == PURPLE -> stdout"), "", resolve)
+	if syntax, ok := err.(*ErrSyntax); !ok || syntax.Line != 3 {
+		t.Errorf("Expected a syntax error on line 3, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package rules
+
+import (
+	"math"
+	"time"
+
+	protos "github.com/pogodevorg/POGOProtos-go"
+
+	"github.com/pogodevorg/pgoapi-go/api"
+	"github.com/pogodevorg/pgoapi-go/feed"
+)
+
+// Wild Pokémon hidden for longer than this have an unknown despawn time
+const maxTimeTillHidden = time.Hour
+
+// subject is a message an expression is evaluated against, with the entry it was received with
+type subject struct {
+	message interface{}
+	entry   *api.FeedEntry
+}
+
+// pokemon returns the wild Pokémon of the message
+func (s *subject) pokemon() *protos.WildPokemon {
+	switch m := s.message.(type) {
+	case *protos.WildPokemon:
+		return m
+	case *api.PokemonAppearedEvent:
+		return m.Pokemon.Pokemon
+	case *api.PokemonDespawnedEvent:
+		return m.Pokemon.Pokemon
+	}
+	return nil
+}
+
+// fort returns the fort of the message, the current state for changed gyms
+func (s *subject) fort() *protos.FortData {
+	switch m := s.message.(type) {
+	case *protos.FortData:
+		return m
+	case *api.GymChangedEvent:
+		return m.Current
+	case *api.FortCooldownEndedEvent:
+		return m.Fort
+	case *api.LureAddedEvent:
+		return m.Fort
+	case *api.LureExpiredEvent:
+		return m.Fort
+	}
+	return nil
+}
+
+// location returns the location of the object in the message, or the location the entry was received at
+func (s *subject) location() *api.Location {
+	if pokemon := s.pokemon(); pokemon != nil {
+		return &api.Location{Lat: pokemon.Latitude, Lon: pokemon.Longitude}
+	}
+	if fort := s.fort(); fort != nil {
+		return &api.Location{Lat: fort.Latitude, Lon: fort.Longitude}
+	}
+	switch m := s.message.(type) {
+	case *protos.MapPokemon:
+		return &api.Location{Lat: m.Latitude, Lon: m.Longitude}
+	case *api.ChallengeEvent:
+		return &m.Location
+	}
+	if s.entry != nil && s.entry.Location != (api.Location{}) {
+		location := s.entry.Location
+		return &location
+	}
+	return nil
+}
+
+// despawnIn returns the seconds until the wild Pokémon of the message despawns, zero when it has despawned
+func (s *subject) despawnIn() interface{} {
+	switch m := s.message.(type) {
+	case *protos.WildPokemon:
+		hidden := time.Duration(m.TimeTillHiddenMs) * time.Millisecond
+		if hidden > 0 && hidden <= maxTimeTillHidden {
+			return hidden.Seconds()
+		}
+	case *api.PokemonAppearedEvent:
+		if !m.Pokemon.DespawnTime.IsZero() {
+			return math.Max(0, m.Pokemon.DespawnTime.Sub(m.Timestamp).Seconds())
+		}
+	}
+	return nil
+}
+
+// field returns the value of the field, or nil when the message does not have it
+func (s *subject) field(name string) interface{} {
+	switch name {
+	case "type":
+		return feed.TypeName(s.message)
+	case "lat", "lon":
+		location := s.location()
+		if location == nil {
+			return nil
+		}
+		if name == "lat" {
+			return location.Lat
+		}
+		return location.Lon
+	case "despawn_in":
+		return s.despawnIn()
+	case "username", "provider", "request_type":
+		if s.entry == nil {
+			return nil
+		}
+		switch name {
+		case "username":
+			return s.entry.Username
+		case "provider":
+			return s.entry.Provider
+		}
+		return float64(s.entry.RequestType)
+	case "species":
+		if pokemon := s.pokemon(); pokemon != nil {
+			return float64(pokemon.GetPokemonData().GetPokemonId())
+		}
+		if m, ok := s.message.(*protos.MapPokemon); ok {
+			return float64(m.PokemonId)
+		}
+		return nil
+	case "previous_team":
+		if m, ok := s.message.(*api.GymChangedEvent); ok {
+			return float64(m.Previous.OwnedByTeam)
+		}
+		return nil
+	case "fort_id":
+		switch m := s.message.(type) {
+		case *api.LureAddedEvent:
+			return m.FortID
+		case *api.LureExpiredEvent:
+			return m.FortID
+		}
+	case "lured":
+		switch s.message.(type) {
+		case *api.LureAddedEvent:
+			return true
+		case *api.LureExpiredEvent:
+			return false
+		}
+	}
+
+	fort := s.fort()
+	if fort == nil {
+		return nil
+	}
+	switch name {
+	case "team":
+		return float64(fort.OwnedByTeam)
+	case "fort_id":
+		return fort.Id
+	case "fort_type":
+		return float64(fort.Type)
+	case "gym_points":
+		return float64(fort.GymPoints)
+	case "lured":
+		return fort.LureInfo != nil
+	}
+	return nil
+}